@@ -0,0 +1,97 @@
+// Package goboot containing the audit trail of the mutating requests. Router emits an audit event
+// for every POST, PUT, PATCH and DELETE request after the response is written, so no endpoint
+// is missed by per handler instrumentation.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/julienschmidt/httprouter"
+)
+
+type auditSession struct {
+	Key string
+}
+
+var auditSessionKey = auditSession{Key: "AuditSession"}
+
+// AuditEvent audit event of the mutating request. Action is the method and route pattern,
+// Resource has the route params and Outcome is success or failure based on the Status.
+type AuditEvent struct {
+	UserID   string            `json:"user_id"`
+	Action   string            `json:"action"`
+	Resource map[string]string `json:"resource"`
+	Time     time.Time         `json:"time"`
+	Status   int               `json:"status"`
+	Outcome  string            `json:"outcome"`
+}
+
+// SetAuditSink sets the sink receiving the audit events of the mutating requests, it applies to
+// the host routers too unless they have their own sink.
+func (ar *Router) SetAuditSink(fn func(AuditEvent)) {
+	ar.auditSink = fn
+}
+
+// audit returns the audit sink of the router or of its parent router
+func (ar *Router) audit() func(AuditEvent) {
+	if ar.auditSink == nil && ar.parent != nil {
+		return ar.parent.audit()
+	}
+	return ar.auditSink
+}
+
+// serveAudited serves the request and emits its audit event. Event is emitted even if the handler
+// panics, with status 500 when the panic isn't recovered down the chain.
+func serveAudited(sink func(AuditEvent), w http.ResponseWriter, r *http.Request, ps httprouter.Params, h http.Handler) {
+	// session is set by the auth middleware down the chain, holder lets it be seen here
+	session := &jwt.MapClaims{}
+	r = r.WithContext(context.WithValue(r.Context(), auditSessionKey, session))
+	t := time.Now()
+	sw := &statusWriter{ResponseWriter: w}
+	defer func() {
+		rr := recover()
+		status := sw.statusCode()
+		if rr != nil {
+			status = http.StatusInternalServerError
+		}
+
+		resource := make(map[string]string, len(ps))
+		for _, p := range ps {
+			resource[p.Key] = p.Value
+		}
+		uid, _ := (*session)["uid"].(string)
+		outcome := "success"
+		if status >= 400 {
+			outcome = "failure"
+		}
+		sink(AuditEvent{
+			UserID:   uid,
+			Action:   r.Method + " " + RoutePattern(r),
+			Resource: resource,
+			Time:     t,
+			Status:   status,
+			Outcome:  outcome,
+		})
+		if rr != nil {
+			panic(rr)
+		}
+	}()
+	h.ServeHTTP(sw, r)
+}
+
+// withSession sets the session user claims into the request context
+func withSession(r *http.Request, claims jwt.MapClaims) *http.Request {
+	if session, ok := r.Context().Value(auditSessionKey).(*jwt.MapClaims); ok {
+		*session = claims
+	}
+	b := context.WithValue(r.Context(), SessionUserKey, claims)
+	return r.WithContext(b)
+}
+
+func isMutating(method string) bool {
+	return method == "POST" || method == "PUT" || method == "PATCH" || method == "DELETE"
+}
@@ -0,0 +1,107 @@
+// Package goboot containing the pluggable authentication strategies. Strategies like JWT, API key
+// or session cookie are tried in the order they were added and the first one that succeeds sets
+// the session user claims, so different clients can authenticate against the same endpoints.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+type authStrategyName struct {
+	Key string
+}
+
+// AuthStrategyKey key for the name of the strategy that authenticated the request
+var AuthStrategyKey = authStrategyName{Key: "AuthStrategy"}
+
+type authStrategy struct {
+	name string
+	fn   func(*http.Request) (jwt.MapClaims, error)
+}
+
+// AddAuthStrategy adds the authentication strategy, fn returns the session user claims when
+// it authenticates the request.
+func (ar *Router) AddAuthStrategy(name string, fn func(*http.Request) (jwt.MapClaims, error)) {
+	ar.authStrategies = append(ar.authStrategies, authStrategy{name: name, fn: fn})
+}
+
+// AuthHandler middleware authenticates the request using the router auth strategies. Session
+// user claims are set into the context by the first strategy that succeeds, if all of them
+// fail request is UnAuthorized.
+func (ar *Router) AuthHandler(ctx context.Context, e ErrorHandler) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "OPTIONS" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			for _, s := range ar.authStrategies {
+				claims, err := s.fn(r)
+				if err != nil || claims == nil {
+					continue
+				}
+				r = withSession(r, claims)
+				b := context.WithValue(r.Context(), AuthStrategyKey, s.name)
+				next.ServeHTTP(w, r.WithContext(b))
+				return
+			}
+
+			log.Printf("[ERROR] Request not authenticated by any of the auth strategies")
+			e.HandleError(r, errors.New("Unauthorized access. All the auth strategies failed"))
+			WriteError(w, UnAuthorized)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+
+	return m
+}
+
+// AuthStrategy returns the name of the strategy that authenticated the request
+func AuthStrategy(r *http.Request) string {
+	name, _ := r.Context().Value(AuthStrategyKey).(string)
+	return name
+}
+
+// JWTAuthStrategy auth strategy validating the bearer JWT token of the Authorization header
+func JWTAuthStrategy(secretAuthToken string) func(*http.Request) (jwt.MapClaims, error) {
+	return func(r *http.Request) (jwt.MapClaims, error) {
+		return checkJWT(nil, r, secretAuthToken)
+	}
+}
+
+// APIKeyAuthStrategy auth strategy checking the API key of the Authorization header, claims
+// are set to the given claims identifying the API client
+func APIKeyAuthStrategy(apiKey string, claims jwt.MapClaims) func(*http.Request) (jwt.MapClaims, error) {
+	if claims == nil {
+		claims = jwt.MapClaims{}
+	}
+	return func(r *http.Request) (jwt.MapClaims, error) {
+		key, err := extractAPIKeyFromAuthHeader(r)
+		if err != nil {
+			return nil, err
+		}
+		if key != apiKey {
+			return nil, errors.New("Invalid API Key. Unauthorized access")
+		}
+		return claims, nil
+	}
+}
+
+// BearerToken returns the raw token of the "Authorization: Bearer <token>" header without
+// verifying it, empty if the header is missing or malformed. Scheme is case insensitive.
+func BearerToken(r *http.Request) string {
+	parts := strings.Fields(r.Header.Get("Authorization"))
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+		return ""
+	}
+	return parts[1]
+}
@@ -0,0 +1,41 @@
+// Package goboot containing the helpers for batch endpoints that process multiple items in a
+// single request and report success or failure of each item.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"net/http"
+)
+
+// ItemResult represents the outcome of a single item of the batch operation. Retryable
+// marks the item failed on a transient error which client should resubmit.
+type ItemResult struct {
+	ID        string `json:"id"`
+	Status    int    `json:"status"`
+	Error     *Error `json:"error,omitempty"`
+	Retryable bool   `json:"retryable,omitempty"`
+}
+
+// MultiStatus response body of the batch operation, Retryable lists the ids of the
+// items client should retry instead of resubmitting the whole batch.
+type MultiStatus struct {
+	Results   []ItemResult `json:"results"`
+	Retryable []string     `json:"retryable,omitempty"`
+}
+
+// WriteMultiStatus writes 207 Multi-Status response with result of each item following
+// the WebDAV multi-status convention.
+func WriteMultiStatus(w http.ResponseWriter, results []ItemResult) {
+	ms := MultiStatus{Results: results}
+	if ms.Results == nil {
+		ms.Results = []ItemResult{}
+	}
+	for _, res := range results {
+		if res.Retryable {
+			ms.Retryable = append(ms.Retryable, res.ID)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusMultiStatus)
+	encodeJSON(w, ms)
+}
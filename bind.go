@@ -0,0 +1,221 @@
+// Package goboot containing the helpers to bind the request data into structs, declared using
+// struct tags instead of extracting and converting each value by hand.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+)
+
+// MaxJSONArrayElements maximum number of elements accepted by BindJSONArray
+var MaxJSONArrayElements = 1000
+
+// StrictJSON rejects the request bodies with unknown JSON fields instead of ignoring them, routes
+// can override it using StrictJSONHandler
+var StrictJSON = false
+
+type strictJSON struct {
+	Key string
+}
+
+var strictJSONKey = strictJSON{Key: "StrictJSON"}
+
+var (
+	// ErrNotJSONArray error for the request body which is not a JSON array
+	ErrNotJSONArray = errors.New("request body must be a JSON array")
+	// ErrTooManyJSONArrayElements error for the JSON array body with more than MaxJSONArrayElements
+	ErrTooManyJSONArrayElements = errors.New("request body JSON array has too many elements")
+)
+
+// BindQuery fills the struct pointed by v from the query params, using the `query` tag as the
+// param name. Values are converted to the field type, `default` tag sets the value of missing
+// param and `required:"true"` makes the param mandatory. Slice fields take repeated params.
+//
+//  type search struct {
+//      Term  string   `query:"q" required:"true"`
+//      Page  int      `query:"page" default:"1"`
+//      Tags  []string `query:"tag"`
+//  }
+func BindQuery(r *http.Request, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("BindQuery requires a pointer to struct")
+	}
+
+	query := r.URL.Query()
+	rv = rv.Elem()
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := sf.Tag.Get("query")
+		if name == "" || sf.PkgPath != "" {
+			continue
+		}
+
+		values := query[name]
+		if len(values) == 0 || values[0] == "" {
+			if def, ok := sf.Tag.Lookup("default"); ok {
+				values = []string{def}
+			} else if sf.Tag.Get("required") == "true" {
+				return fmt.Errorf("missing required query param %s", name)
+			} else {
+				continue
+			}
+		}
+		if err := setField(rv.Field(i), values); err != nil {
+			return fmt.Errorf("invalid query param %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// BindJSONArray decodes the JSON array request body into the slice pointed by v. Bodies which are
+// not an array or have more than MaxJSONArrayElements elements are rejected with ErrNotJSONArray
+// and ErrTooManyJSONArrayElements, WriteBodyError responds to them with 400 and a clear detail.
+// The limit is checked while decoding so oversized arrays are never fully decoded.
+func BindJSONArray(r *http.Request, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return errors.New("BindJSONArray requires a pointer to slice")
+	}
+	if r.Body == nil {
+		return ErrNotJSONArray
+	}
+
+	dec := newJSONDecoder(r)
+	if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+		if err != nil && err.Error() == ErrBodyTooLarge.Error() {
+			return err
+		}
+		return ErrNotJSONArray
+	}
+
+	s := reflect.MakeSlice(rv.Elem().Type(), 0, 0)
+	elemType := s.Type().Elem()
+	for dec.More() {
+		if s.Len() >= MaxJSONArrayElements {
+			return ErrTooManyJSONArrayElements
+		}
+		elem := reflect.New(elemType)
+		if err := dec.Decode(elem.Interface()); err != nil {
+			return err
+		}
+		s = reflect.Append(s, elem.Elem())
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	rv.Elem().Set(s)
+	return nil
+}
+
+// StreamJSONArray reads the JSON array request body element by element calling fn for each of
+// them, so huge arrays are processed without loading them in memory. It stops at the first
+// error returned by fn and returns it.
+func StreamJSONArray(r *http.Request, fn func(json.RawMessage) error) error {
+	if r.Body == nil {
+		return ErrNotJSONArray
+	}
+
+	dec := json.NewDecoder(r.Body)
+	if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+		if err != nil && err.Error() == ErrBodyTooLarge.Error() {
+			return err
+		}
+		return ErrNotJSONArray
+	}
+	for dec.More() {
+		var elem json.RawMessage
+		if err := dec.Decode(&elem); err != nil {
+			return err
+		}
+		if err := fn(elem); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// StrictJSONHandler middleware to set whether the route rejects the request bodies with unknown
+// JSON fields, overriding StrictJSON. It must be before the body decoding handlers in the chain.
+func StrictJSONHandler(ctx context.Context, strict bool) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			b := context.WithValue(r.Context(), strictJSONKey, strict)
+			next.ServeHTTP(w, r.WithContext(b))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+
+	return m
+}
+
+// newJSONDecoder returns the request body decoder, disallowing the unknown fields in strict mode
+func newJSONDecoder(r *http.Request) *json.Decoder {
+	dec := json.NewDecoder(r.Body)
+	strict, ok := r.Context().Value(strictJSONKey).(bool)
+	if !ok {
+		strict = StrictJSON
+	}
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	return dec
+}
+
+// setField converts the values to the field type and sets it
+func setField(f reflect.Value, values []string) error {
+	if f.Kind() == reflect.Slice {
+		s := reflect.MakeSlice(f.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(s.Index(i), value); err != nil {
+				return err
+			}
+		}
+		f.Set(s)
+		return nil
+	}
+	return setValue(f, values[0])
+}
+
+func setValue(f reflect.Value, value string) error {
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", f.Type())
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+// Package goboot containing the in-process response cache for the GET requests. Cached responses
+// are keyed on the path, query, session user and the headers listed in CacheVaryHeaders, so the
+// read heavy endpoints don't hit the database on every request.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"bytes"
+	"container/list"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheVaryHeaders request headers which are part of the cache key
+var CacheVaryHeaders = []string{"Accept", "Accept-Encoding", "Accept-Language", EnvelopeVersionHeader}
+
+type cacheEntry struct {
+	key     string
+	path    string
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// responseCache is LRU cache of the responses limited to maxEntries
+type responseCache struct {
+	sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	ll         *list.List
+	entries    map[string]*list.Element
+}
+
+// EnableCache enables the response cache of the router with the given ttl, holding at most
+// maxEntries responses. Routes are cached only when CacheHandler is in their chain.
+func (ar *Router) EnableCache(ttl time.Duration, maxEntries int) {
+	ar.cache = &responseCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// CacheHandler middleware serves GET requests from the router response cache, responses marked
+// no-store and the non 200 responses are never cached. It must be appended after the auth
+// middleware so the responses are cached per user.
+func (ar *Router) CacheHandler(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		c := ar.cache
+		if c == nil || r.Method != "GET" || strings.Contains(r.Header.Get("Cache-Control"), "no-store") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := cacheKey(r)
+		if e := c.get(key); e != nil {
+			for k, v := range e.header {
+				// keep headers set per request like CORS origin
+				if _, ok := w.Header()[k]; !ok {
+					w.Header()[k] = v
+				}
+			}
+			w.WriteHeader(e.status)
+			w.Write(e.body)
+			return
+		}
+
+		cw := &cacheWriter{ResponseWriter: w}
+		next.ServeHTTP(cw, r)
+		if cw.status == http.StatusOK && !strings.Contains(w.Header().Get("Cache-Control"), "no-store") {
+			c.add(&cacheEntry{
+				key:    key,
+				path:   r.URL.Path,
+				status: cw.status,
+				header: cloneHeader(w.Header()),
+				body:   cw.buf.Bytes(),
+			})
+		}
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// InvalidateCache removes the cached responses of the paths matching the pattern, using
+// path.Match syntax e.g. /api/v1/config/*
+func (ar *Router) InvalidateCache(pattern string) {
+	c := ar.cache
+	if c == nil {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	for key, el := range c.entries {
+		if ok, _ := path.Match(pattern, el.Value.(*cacheEntry).path); ok {
+			c.ll.Remove(el)
+			delete(c.entries, key)
+		}
+	}
+}
+
+func (c *responseCache) get(key string) *cacheEntry {
+	c.Lock()
+	defer c.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*cacheEntry)
+	if time.Now().After(e.expires) {
+		c.ll.Remove(el)
+		delete(c.entries, key)
+		return nil
+	}
+	c.ll.MoveToFront(el)
+	return e
+}
+
+func (c *responseCache) add(e *cacheEntry) {
+	c.Lock()
+	defer c.Unlock()
+	e.expires = time.Now().Add(c.ttl)
+	if el, ok := c.entries[e.key]; ok {
+		el.Value = e
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.entries[e.key] = c.ll.PushFront(e)
+	for c.maxEntries > 0 && c.ll.Len() > c.maxEntries {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func cacheKey(r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(r.URL.Path)
+	b.WriteString("?")
+	b.WriteString(r.URL.Query().Encode())
+	b.WriteString("|")
+	b.WriteString(SessionUserID(r))
+	for _, h := range CacheVaryHeaders {
+		b.WriteString("|")
+		b.WriteString(r.Header.Get(h))
+	}
+	return b.String()
+}
+
+func cloneHeader(h http.Header) http.Header {
+	c := make(http.Header, len(h))
+	for k, v := range h {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
+
+// cacheWriter writes the response through while keeping a copy for the cache
+type cacheWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (cw *cacheWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *cacheWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	cw.buf.Write(b)
+	return cw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,155 @@
+// Package goboot containing the Accept-Charset negotiation. Responses are UTF-8 unless the client
+// asks for ISO-8859-1, in which case the JSON and text responses are transcoded on the fly.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ErrNotAcceptable error for the unsupported Accept-Charset
+var ErrNotAcceptable = &Error{"not_acceptable", 406, "Not Acceptable", "Response charset is not supported. Use UTF-8 or ISO-8859-1."}
+
+const (
+	charsetUTF8   = "utf-8"
+	charsetLatin1 = "iso-8859-1"
+)
+
+// charsetAliases maps the supported charset names to the canonical ones
+var charsetAliases = map[string]string{
+	"utf-8":      charsetUTF8,
+	"utf8":       charsetUTF8,
+	"iso-8859-1": charsetLatin1,
+	"iso_8859-1": charsetLatin1,
+	"latin1":     charsetLatin1,
+	"l1":         charsetLatin1,
+}
+
+// CharsetHandler middleware to respond in the charset preferred by the Accept-Charset header,
+// UTF-8 by default. Requests accepting none of the supported charsets are rejected with
+// 406 Not Acceptable.
+func CharsetHandler(ctx context.Context) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Charset")
+			charset, ok := responseCharset(r)
+			if !ok {
+				WriteError(w, ErrNotAcceptable)
+				return
+			}
+			if charset == charsetUTF8 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(&latin1Writer{ResponseWriter: w}, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+
+	return m
+}
+
+// responseCharset returns the most preferred supported charset, false if none is accepted
+func responseCharset(r *http.Request) (string, bool) {
+	if r.Header.Get("Accept-Charset") == "" {
+		return charsetUTF8, true
+	}
+	accepted, rejected := parseAccept(r.Header.Get("Accept-Charset"))
+	refused := make(map[string]bool)
+	for v := range rejected {
+		if charset, ok := charsetAliases[v]; ok {
+			refused[charset] = true
+		}
+	}
+	for _, v := range accepted {
+		if v == "*" {
+			// any charset not rejected with q=0, UTF-8 preferred
+			for _, charset := range []string{charsetUTF8, charsetLatin1} {
+				if !refused[charset] {
+					return charset, true
+				}
+			}
+			continue
+		}
+		if charset, ok := charsetAliases[v]; ok {
+			return charset, true
+		}
+	}
+	return "", false
+}
+
+// latin1Writer transcodes the UTF-8 JSON and text responses to ISO-8859-1. Characters outside of
+// ISO-8859-1 are escaped in JSON, which can only have them in strings, and replaced by ? in text.
+type latin1Writer struct {
+	http.ResponseWriter
+	wroteHeader bool
+	transcode   bool
+	json        bool
+	// pending incomplete UTF-8 sequence at the end of the last write
+	pending []byte
+}
+
+func (lw *latin1Writer) WriteHeader(status int) {
+	if !lw.wroteHeader {
+		lw.wroteHeader = true
+		ct := lw.Header().Get("Content-Type")
+		mediaType := strings.ToLower(strings.TrimSpace(strings.Split(ct, ";")[0]))
+		lw.json = strings.HasSuffix(mediaType, "json")
+		lw.transcode = lw.json || strings.HasPrefix(mediaType, "text/")
+		if lw.transcode {
+			lw.Header().Set("Content-Type", mediaType+"; charset=ISO-8859-1")
+			lw.Header().Del("Content-Length")
+		}
+	}
+	lw.ResponseWriter.WriteHeader(status)
+}
+
+func (lw *latin1Writer) Write(b []byte) (int, error) {
+	if !lw.wroteHeader {
+		lw.WriteHeader(http.StatusOK)
+	}
+	if !lw.transcode {
+		return lw.ResponseWriter.Write(b)
+	}
+
+	data := append(lw.pending, b...)
+	lw.pending = nil
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		if !utf8.FullRune(data[i:]) {
+			lw.pending = append([]byte(nil), data[i:]...)
+			break
+		}
+		c, size := utf8.DecodeRune(data[i:])
+		i += size
+		switch {
+		case c == utf8.RuneError && size == 1:
+			out = append(out, '?')
+		case c < 0x100:
+			out = append(out, byte(c))
+		case lw.json && c > 0xFFFF:
+			r1, r2 := utf16.EncodeRune(c)
+			out = append(out, fmt.Sprintf(`\u%04x\u%04x`, r1, r2)...)
+		case lw.json:
+			out = append(out, fmt.Sprintf(`\u%04x`, c)...)
+		default:
+			out = append(out, '?')
+		}
+	}
+	if _, err := lw.ResponseWriter.Write(out); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (lw *latin1Writer) Flush() {
+	if f, ok := lw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,138 @@
+// Package goboot containing the helpers to identify the client apps calling the API, like
+// the app version check to force upgrade old mobile apps.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// AppVersionHeader header used by the client apps to send their version
+	AppVersionHeader = "X-App-Version"
+	// AppSourceHeader header used by the client apps to identify themselves e.g. ios, android, web
+	AppSourceHeader = "X-App-Source"
+)
+
+// ErrUpdateRequired error for client apps older than the minimum supported version. It's 403
+// rather than 426, which is for the protocol upgrades and requires the Upgrade header.
+var ErrUpdateRequired = &Error{"update_required", 403, "Update Required", "Please update the app to the latest version"}
+
+// AppSource returns the client app source from the request header
+func AppSource(r *http.Request) string {
+	return strings.TrimSpace(r.Header.Get(AppSourceHeader))
+}
+
+// AppVersion returns the client app version from the request header
+func AppVersion(r *http.Request) string {
+	return strings.TrimSpace(r.Header.Get(AppVersionHeader))
+}
+
+// RequireMinAppVersion checks if client app version is at least minVersion using semantic
+// versioning rules, so 1.2.0-beta is older than 1.2.0. Missing or invalid client version
+// doesn't satisfy the minimum, invalid minVersion is ignored and any version is allowed.
+func RequireMinAppVersion(r *http.Request, minVersion string) bool {
+	min, ok := parseVersion(minVersion)
+	if !ok {
+		return true
+	}
+	v, ok := parseVersion(AppVersion(r))
+	if !ok {
+		return false
+	}
+	return compareVersion(v, min) >= 0
+}
+
+// WriteUpdateRequired writes the update required error for stale client apps
+func WriteUpdateRequired(w http.ResponseWriter, minVersion string) {
+	err := *ErrUpdateRequired
+	err.Detail = fmt.Sprintf("Please update the app to version %s or later", minVersion)
+	WriteError(w, &err)
+}
+
+type version struct {
+	core       [3]int
+	prerelease []string
+}
+
+// parseVersion parses version like v1.2.3-beta.1+build, missing minor or patch is 0
+func parseVersion(s string) (version, bool) {
+	var v version
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.Index(s, "+"); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.Index(s, "-"); i >= 0 {
+		v.prerelease = strings.Split(s[i+1:], ".")
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if s == "" || len(parts) > 3 {
+		return v, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v.core[i] = n
+	}
+	return v, true
+}
+
+// compareVersion returns -1, 0 or 1 if a is older, same or newer than b
+func compareVersion(a, b version) int {
+	for i := range a.core {
+		if a.core[i] != b.core[i] {
+			if a.core[i] < b.core[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	// release version is newer than any of its prerelease
+	switch {
+	case len(a.prerelease) == 0 && len(b.prerelease) == 0:
+		return 0
+	case len(a.prerelease) == 0:
+		return 1
+	case len(b.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(a.prerelease) && i < len(b.prerelease); i++ {
+		if c := comparePrerelease(a.prerelease[i], b.prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(a.prerelease) < len(b.prerelease):
+		return -1
+	case len(a.prerelease) > len(b.prerelease):
+		return 1
+	}
+	return 0
+}
+
+// comparePrerelease compares numeric identifiers numerically and lower than alphanumeric ones
+func comparePrerelease(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		if na < nb {
+			return -1
+		} else if na > nb {
+			return 1
+		}
+		return 0
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
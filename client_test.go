@@ -0,0 +1,84 @@
+package goboot
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCompareVersion(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2.3", "1.2.4", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"2.0.0", "1.99.99", 1},
+		// missing minor or patch is 0
+		{"1", "1.0.0", 0},
+		{"1.2", "1.2.0", 0},
+		{"1.2", "1.2.1", -1},
+		// prerelease is older than its release
+		{"1.2.0-beta", "1.2.0", -1},
+		{"1.2.0", "1.2.0-rc.1", 1},
+		{"1.2.0-alpha", "1.1.9", 1},
+		// prerelease identifiers
+		{"1.0.0-alpha", "1.0.0-beta", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-1", "1.0.0-alpha", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-rc.1", "1.0.0-rc.1", 0},
+		// build metadata is ignored
+		{"1.0.0+20130313", "1.0.0", 0},
+	}
+	for _, tt := range tests {
+		a, ok := parseVersion(tt.a)
+		if !ok {
+			t.Fatalf("parseVersion(%q) failed", tt.a)
+		}
+		b, ok := parseVersion(tt.b)
+		if !ok {
+			t.Fatalf("parseVersion(%q) failed", tt.b)
+		}
+		if got := compareVersion(a, b); got != tt.want {
+			t.Errorf("compareVersion(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestParseVersionInvalid(t *testing.T) {
+	for _, s := range []string{"", "v", "1.2.3.4", "1.x", "a.b.c", "1.-2.0", "1..2"} {
+		if _, ok := parseVersion(s); ok {
+			t.Errorf("parseVersion(%q) succeeded, want failure", s)
+		}
+	}
+}
+
+func TestRequireMinAppVersion(t *testing.T) {
+	tests := []struct {
+		version, min string
+		want         bool
+	}{
+		{"2.1.0", "2.0.0", true},
+		{"2.0.0", "2.0.0", true},
+		{"1.9.9", "2.0.0", false},
+		{"2.0.0-beta", "2.0.0", false},
+		{"2", "2.0.0", true},
+		// missing or invalid client version doesn't satisfy the minimum
+		{"", "2.0.0", false},
+		{"latest", "2.0.0", false},
+		// invalid minimum allows any version
+		{"1.0.0", "", true},
+		{"", "not-a-version", true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.version != "" {
+			r.Header.Set(AppVersionHeader, tt.version)
+		}
+		if got := RequireMinAppVersion(r, tt.min); got != tt.want {
+			t.Errorf("RequireMinAppVersion(%q, %q) = %v, want %v", tt.version, tt.min, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Package goboot containing the response compression. Content coding is negotiated from the
+// Accept-Encoding header among the registered compression backends, gzip is built in and zstd
+// with a shared dictionary of the common field names can be registered for the SDK clients.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compressor compression backend of the content coding, see NewZstdDictCompressor for the zstd
+// with a dictionary.
+type Compressor interface {
+	// Encoding returns the content coding name, like gzip or zstd
+	Encoding() string
+	// NewWriter returns writer compressing the data written to it into w
+	NewWriter(w io.Writer) io.WriteCloser
+}
+
+var (
+	compressorsMu sync.RWMutex
+	// compressors in the order of server preference
+	compressors = []Compressor{gzipCompressor{}}
+)
+
+// RegisterCompressor registers the compression backend, preferred over the ones registered before
+// when the client accepts both. It replaces the backend registered for the same content coding.
+func RegisterCompressor(c Compressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	registered := []Compressor{c}
+	for _, rc := range compressors {
+		if rc.Encoding() != c.Encoding() {
+			registered = append(registered, rc)
+		}
+	}
+	compressors = registered
+}
+
+// CompressionHandler middleware to compress the response with the content coding negotiated from
+// the Accept-Encoding header, the response is not compressed if the client accepts none of the
+// registered ones.
+func CompressionHandler(ctx context.Context) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			c := negotiateCompressor(r)
+			if c == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, compressor: c}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+
+	return m
+}
+
+// negotiateCompressor returns the registered compressor most preferred by the client, nil for
+// the identity coding. Wildcard * selects only gzip, unless rejected with q=0, as the other
+// backends need the client to know e.g. the dictionary.
+func negotiateCompressor(r *http.Request) Compressor {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	accepted, rejected := parseAccept(r.Header.Get("Accept-Encoding"))
+	for _, encoding := range accepted {
+		if encoding == "identity" {
+			return nil
+		}
+		if encoding == "*" && !rejected["gzip"] {
+			encoding = "gzip"
+		}
+		for _, c := range compressors {
+			if c.Encoding() == encoding {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// NewZstdDictCompressor returns zstd compression backend using the dictionary trained with
+// zstd --train on the typical responses, under the given content coding. Browsers advertise the
+// plain zstd coding and can't decode the dictionary compressed responses, so the coding must be
+// a custom token known to the SDK clients naming the dictionary, like x-zstd-dict-v1, and changed
+// whenever the dictionary changes.
+func NewZstdDictCompressor(encoding string, dict []byte) (Compressor, error) {
+	encoding = strings.ToLower(encoding)
+	if encoding == "" || encoding == "zstd" || encoding == "gzip" || encoding == "identity" {
+		return nil, fmt.Errorf("dictionary compression needs its own content coding, got %q", encoding)
+	}
+	// validates the dictionary
+	if _, err := zstd.NewWriter(nil, zstd.WithEncoderDict(dict), zstd.WithEncoderConcurrency(1)); err != nil {
+		return nil, err
+	}
+
+	c := &zstdDictCompressor{encoding: encoding}
+	c.encoders.New = func() interface{} {
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderDict(dict), zstd.WithEncoderConcurrency(1))
+		return enc
+	}
+	return c, nil
+}
+
+// zstdDictCompressor pools the encoders, creating one with the dictionary is expensive
+type zstdDictCompressor struct {
+	encoding string
+	encoders sync.Pool
+}
+
+func (c *zstdDictCompressor) Encoding() string {
+	return c.encoding
+}
+
+func (c *zstdDictCompressor) NewWriter(w io.Writer) io.WriteCloser {
+	enc := c.encoders.Get().(*zstd.Encoder)
+	enc.Reset(w)
+	return &zstdWriter{Encoder: enc, pool: &c.encoders}
+}
+
+// zstdWriter returns the encoder to the pool when closed
+type zstdWriter struct {
+	*zstd.Encoder
+	pool *sync.Pool
+}
+
+func (zw *zstdWriter) Close() error {
+	err := zw.Encoder.Close()
+	zw.pool.Put(zw.Encoder)
+	return err
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Encoding() string {
+	return "gzip"
+}
+
+func (gzipCompressor) NewWriter(w io.Writer) io.WriteCloser {
+	return gzip.NewWriter(w)
+}
+
+// compressWriter compresses the response body, responses without body or already encoded by
+// the handler are written as is
+type compressWriter struct {
+	http.ResponseWriter
+	compressor  Compressor
+	writer      io.WriteCloser
+	wroteHeader bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		h := cw.Header()
+		if status != http.StatusNoContent && status != http.StatusNotModified && h.Get("Content-Encoding") == "" {
+			h.Set("Content-Encoding", cw.compressor.Encoding())
+			h.Del("Content-Length")
+			cw.writer = cw.compressor.NewWriter(cw.ResponseWriter)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.writer == nil {
+		return cw.ResponseWriter.Write(b)
+	}
+	return cw.writer.Write(b)
+}
+
+func (cw *compressWriter) Flush() {
+	if f, ok := cw.writer.(interface {
+		Flush() error
+	}); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// close writes the end of the compressed stream
+func (cw *compressWriter) close() {
+	if cw.writer != nil {
+		cw.writer.Close()
+	}
+}
@@ -0,0 +1,191 @@
+// Package goboot containing the contract capture. When enabled, router records example request and
+// response pairs of the sampled requests per route, with the sensitive fields redacted, to
+// bootstrap the contract tests from the real traffic.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// ContractSampleRate captures 1 in ContractSampleRate requests
+	ContractSampleRate = 100
+	// RedactedFields names of the JSON fields, query params and headers redacted in the captured
+	// examples, compared case insensitively
+	RedactedFields = []string{"password", "token", "secret", "api_key", "signature", "authorization", "cookie", "set-cookie"}
+)
+
+// maxContractBody bodies larger than this are not captured
+const maxContractBody = 64 << 10
+
+// redactedValue replaces the values of the redacted fields
+const redactedValue = "[REDACTED]"
+
+// ContractExample captured request and response pair of the route. Bodies are omitted if they
+// aren't JSON or are too large.
+type ContractExample struct {
+	Method          string          `json:"method"`
+	Path            string          `json:"path"`
+	Query           string          `json:"query,omitempty"`
+	RequestHeaders  http.Header     `json:"request_headers,omitempty"`
+	RequestBody     json.RawMessage `json:"request_body,omitempty"`
+	Status          int             `json:"status"`
+	ResponseHeaders http.Header     `json:"response_headers,omitempty"`
+	ResponseBody    json.RawMessage `json:"response_body,omitempty"`
+	Time            time.Time       `json:"time"`
+}
+
+// EnableContractCapture sets the sink receiving the captured examples of the sampled requests
+// with their route pattern, it applies to the host routers too unless they have their own sink.
+func (ar *Router) EnableContractCapture(sink func(route string, example ContractExample)) {
+	ar.contractSink = sink
+}
+
+// contractCapture returns the contract sink of the router or of its parent router
+func (ar *Router) contractCapture() func(string, ContractExample) {
+	if ar.contractSink == nil && ar.parent != nil {
+		return ar.parent.contractCapture()
+	}
+	return ar.contractSink
+}
+
+// captureContract wraps the handler to capture the request and response example
+func captureContract(sink func(string, ContractExample), h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ex := ContractExample{
+			Method:         r.Method,
+			Path:           r.URL.Path,
+			Query:          redactQuery(r.URL.Query()),
+			RequestHeaders: redactHeader(r.Header),
+			Time:           time.Now(),
+		}
+		// body is captured only as the handler reads it, so requests rejected by the middleware
+		// before reading it, like the unauthenticated ones, are never read
+		reqBody := &captureBuffer{}
+		if r.Body != nil {
+			r.Body = &contractBody{Reader: io.TeeReader(r.Body, reqBody), Closer: r.Body}
+		}
+
+		cw := &contractWriter{statusWriter: statusWriter{ResponseWriter: w}}
+		h.ServeHTTP(cw, r)
+
+		ex.Status = cw.statusCode()
+		ex.ResponseHeaders = redactHeader(w.Header())
+		if !reqBody.truncated {
+			// partially read body isn't valid JSON and is left out
+			ex.RequestBody = redactJSON(reqBody.Bytes())
+		}
+		if !cw.body.truncated {
+			ex.ResponseBody = redactJSON(cw.body.Bytes())
+		}
+		sink(RoutePattern(r), ex)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// redactQuery returns the encoded query with the redacted params values replaced
+func redactQuery(q url.Values) string {
+	for k, v := range q {
+		if isRedacted(k) {
+			for i := range v {
+				v[i] = redactedValue
+			}
+		}
+	}
+	return q.Encode()
+}
+
+// redactHeader returns copy of the header with the redacted headers values replaced
+func redactHeader(h http.Header) http.Header {
+	c := cloneHeader(h)
+	for k, v := range c {
+		if isRedacted(k) {
+			for i := range v {
+				v[i] = redactedValue
+			}
+		}
+	}
+	return c
+}
+
+// redactJSON returns the JSON with the redacted fields values replaced, nil if it's not JSON
+func redactJSON(b []byte) json.RawMessage {
+	var v interface{}
+	if len(b) == 0 || json.Unmarshal(b, &v) != nil {
+		return nil
+	}
+	redactValue(v)
+	out, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+func redactValue(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if isRedacted(k) {
+				v[k] = redactedValue
+				continue
+			}
+			redactValue(e)
+		}
+	case []interface{}:
+		for _, e := range v {
+			redactValue(e)
+		}
+	}
+}
+
+func isRedacted(name string) bool {
+	for _, f := range RedactedFields {
+		if strings.EqualFold(f, name) {
+			return true
+		}
+	}
+	return false
+}
+
+type contractBody struct {
+	io.Reader
+	io.Closer
+}
+
+// captureBuffer keeps the data written to it up to maxContractBody, it never fails the write
+type captureBuffer struct {
+	bytes.Buffer
+	truncated bool
+}
+
+func (cb *captureBuffer) Write(b []byte) (int, error) {
+	if !cb.truncated {
+		if cb.Len()+len(b) > maxContractBody {
+			cb.truncated = true
+			cb.Reset()
+		} else {
+			cb.Buffer.Write(b)
+		}
+	}
+	return len(b), nil
+}
+
+// contractWriter records the response status and the body up to maxContractBody
+type contractWriter struct {
+	statusWriter
+	body captureBuffer
+}
+
+func (cw *contractWriter) Write(b []byte) (int, error) {
+	cw.body.Write(b)
+	return cw.statusWriter.Write(b)
+}
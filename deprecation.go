@@ -0,0 +1,135 @@
+// Package goboot containing the support for deprecated routes. Deprecated routes respond with
+// the Deprecation header and their usage is counted per client app source, so we know when
+// it's safe to remove them.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// deprecationEventsBuffer events waiting for the sink, events are dropped when it's full
+const deprecationEventsBuffer = 1024
+
+// MaxDeprecationSources distinct app sources counted per deprecated route, hits of the further
+// sources and of the sources longer than 64 characters are counted as other. App source is
+// a client header, the cap keeps the counts bounded whatever the clients send.
+var MaxDeprecationSources = 32
+
+// DeprecationEvent telemetry event of the deprecated route hit
+type DeprecationEvent struct {
+	Path      string    `json:"path"`
+	AppSource string    `json:"app_source"`
+	UserID    string    `json:"user_id"`
+	Time      time.Time `json:"time"`
+}
+
+// deprecationStats hit counts of the deprecated routes by app source
+type deprecationStats struct {
+	sync.Mutex
+	counts map[string]map[string]int64
+}
+
+// GetDeprecatedTracked registers GET route marked deprecated, counting its hits per app source
+func (ar *Router) GetDeprecatedTracked(path string, handler http.Handler) {
+	ar.Get(path, ar.deprecated(path, handler))
+}
+
+// SetDeprecationSink sets the sink receiving the event of every deprecated route hit, it applies
+// to the host routers too unless they have their own sink. Events are sent to the sink from
+// a separate goroutine so it doesn't slow down the requests, events are dropped if the sink can't
+// keep up.
+func (ar *Router) SetDeprecationSink(fn func(DeprecationEvent)) {
+	events := make(chan DeprecationEvent, deprecationEventsBuffer)
+	go func() {
+		for e := range events {
+			fn(e)
+		}
+	}()
+	ar.deprecationEvents.Store(events)
+}
+
+// deprecationSink returns the deprecation events channel of the router or of its parent router
+func (ar *Router) deprecationSink() chan DeprecationEvent {
+	events, _ := ar.deprecationEvents.Load().(chan DeprecationEvent)
+	if events == nil && ar.parent != nil {
+		return ar.parent.deprecationSink()
+	}
+	return events
+}
+
+// DeprecatedUsage returns hit counts of the deprecated routes of the router and its host routers
+// by route path and app source
+func (ar *Router) DeprecatedUsage() map[string]map[string]int64 {
+	usage := make(map[string]map[string]int64)
+	ar.addDeprecatedUsage(usage)
+	return usage
+}
+
+// addDeprecatedUsage adds the hit counts of the router and its host routers to usage
+func (ar *Router) addDeprecatedUsage(usage map[string]map[string]int64) {
+	if ds := ar.deprecations; ds != nil {
+		ds.Lock()
+		for path, counts := range ds.counts {
+			if usage[path] == nil {
+				usage[path] = make(map[string]int64, len(counts))
+			}
+			for source, n := range counts {
+				usage[path][source] += n
+			}
+		}
+		ds.Unlock()
+	}
+	for _, hr := range ar.hosts {
+		hr.router.addDeprecatedUsage(usage)
+	}
+}
+
+// DeprecatedUsageHandler handler to expose the deprecated routes usage on the debug endpoint
+func (ar *Router) DeprecatedUsageHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		DataResponse(ar.DeprecatedUsage()).Write(w, r)
+	})
+}
+
+// deprecated wraps the handler to set Deprecation header and track the route usage
+func (ar *Router) deprecated(path string, handler http.Handler) http.Handler {
+	if ar.deprecations == nil {
+		ar.deprecations = &deprecationStats{counts: make(map[string]map[string]int64)}
+	}
+	ds := ar.deprecations
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		source := AppSource(r)
+		if source == "" {
+			source = "unknown"
+		}
+
+		ds.Lock()
+		counts := ds.counts[path]
+		if counts == nil {
+			counts = make(map[string]int64)
+			ds.counts[path] = counts
+		}
+		bucket := source
+		if _, ok := counts[bucket]; !ok && (len(bucket) > 64 || len(counts) >= MaxDeprecationSources) {
+			bucket = "other"
+		}
+		counts[bucket]++
+		ds.Unlock()
+
+		if events := ar.deprecationSink(); events != nil {
+			select {
+			case events <- DeprecationEvent{Path: path, AppSource: source, UserID: SessionUserID(r), Time: time.Now()}:
+			default:
+				log.Printf("[WARN] Deprecation event dropped for %s", path)
+			}
+		}
+
+		w.Header().Set("Deprecation", "true")
+		handler.ServeHTTP(w, r)
+	})
+}
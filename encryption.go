@@ -0,0 +1,269 @@
+// Package goboot containing the field level encryption for request and response data. Struct fields
+// tagged with `encrypt:"true"` are decrypted after JSON body is decoded and encrypted before the
+// response is written, so handlers only ever see plaintext.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+)
+
+// KMS encrypts and decrypts the field values. Implement it using your key management service.
+type KMS interface {
+	Encrypt(plaintext string) (string, error)
+	Decrypt(ciphertext string) (string, error)
+}
+
+// EncryptFields returns copy of v with all the string fields tagged `encrypt:"true"` encrypted.
+// Tagged fields can be string, *string or []string, other types are an error.
+// Nested structs, pointers, slices and maps are walked as well. Empty values are left as is.
+// Embedded pointers to unexported structs can't be copied, they are an error if they might have
+// tagged fields.
+func EncryptFields(v interface{}, kms KMS) (interface{}, error) {
+	return cryptFields(v, kms.Encrypt)
+}
+
+// DecryptFields returns copy of v with all the string fields tagged `encrypt:"true"` decrypted.
+// Tagged fields can be string, *string or []string, other types are an error.
+func DecryptFields(v interface{}, kms KMS) (interface{}, error) {
+	return cryptFields(v, kms.Decrypt)
+}
+
+// DecryptBodyHandler decrypts the encrypted fields of the request body set by JSONBodyHandler,
+// so it must be appended after JSONBodyHandler in the chain.
+func DecryptBodyHandler(ctx context.Context, kms KMS, e ErrorHandler) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if v := RequestBody(r); v != nil {
+				val, err := DecryptFields(v, kms)
+				if err != nil {
+					log.Printf("[ERROR] Error decrypting request body fields: %s", err)
+					e.HandleError(r, err)
+					WriteError(w, ErrBadRequest)
+					return
+				}
+				b := context.WithValue(r.Context(), Body, val)
+				r = r.WithContext(b)
+			}
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+
+	return m
+}
+
+// EncryptedResponseHandler works like ResponseHandler but encrypts the fields of the API response
+// data before writing it to the network output.
+func EncryptedResponseHandler(kms KMS, f func(http.ResponseWriter, *http.Request) Response) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := f(w, r)
+		if res, ok := response.(APIResponse); ok && res.Data != nil {
+			data, err := EncryptFields(res.Data, kms)
+			if err != nil {
+				log.Printf("[ERROR] Error encrypting response fields: %s", err)
+				WriteError(w, ErrInternalServer)
+				return
+			}
+			res.Data = data
+			response = res
+		}
+		response.Write(w, r)
+	}
+}
+
+func cryptFields(v interface{}, fn func(string) (string, error)) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	val, err := copyCryptValue(reflect.ValueOf(v), fn)
+	if err != nil {
+		return nil, err
+	}
+	return val.Interface(), nil
+}
+
+// copyCryptValue copies the value applying fn to the tagged fields, so the original value which
+// might be shared with the caller is never modified.
+func copyCryptValue(v reflect.Value, fn func(string) (string, error)) (reflect.Value, error) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v, nil
+		}
+		e, err := copyCryptValue(v.Elem(), fn)
+		if err != nil {
+			return v, err
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(e)
+		return p, nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return v, nil
+		}
+		e, err := copyCryptValue(v.Elem(), fn)
+		if err != nil {
+			return v, err
+		}
+		i := reflect.New(v.Type()).Elem()
+		i.Set(e)
+		return i, nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return v, nil
+		}
+		s := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			e, err := copyCryptValue(v.Index(i), fn)
+			if err != nil {
+				return v, err
+			}
+			s.Index(i).Set(e)
+		}
+		return s, nil
+	case reflect.Array:
+		a := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			e, err := copyCryptValue(v.Index(i), fn)
+			if err != nil {
+				return v, err
+			}
+			a.Index(i).Set(e)
+		}
+		return a, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return v, nil
+		}
+		m := reflect.MakeMap(v.Type())
+		for _, k := range v.MapKeys() {
+			e, err := copyCryptValue(v.MapIndex(k), fn)
+			if err != nil {
+				return v, err
+			}
+			m.SetMapIndex(k, e)
+		}
+		return m, nil
+	case reflect.Struct:
+		s := reflect.New(v.Type()).Elem()
+		s.Set(v)
+		if err := copyCryptFields(v, s, fn); err != nil {
+			return v, err
+		}
+		return s, nil
+	}
+
+	return v, nil
+}
+
+// copyCryptFields sets the fields of the struct copy s applying fn to the tagged fields of v.
+// Exported fields of the embedded unexported structs are walked too, encoding/json promotes them.
+func copyCryptFields(v, s reflect.Value, fn func(string) (string, error)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		f := v.Field(i)
+		if sf.PkgPath != "" {
+			// unexported field, only the embedded structs have fields in the JSON
+			if !sf.Anonymous {
+				continue
+			}
+			switch {
+			case sf.Type.Kind() == reflect.Struct:
+				if err := copyCryptFields(f, s.Field(i), fn); err != nil {
+					return err
+				}
+			case sf.Type.Kind() == reflect.Ptr && !f.IsNil() && hasCryptFields(sf.Type, map[reflect.Type]bool{}):
+				// pointer can't be replaced with the copy, fail rather than send the fields in plain
+				return fmt.Errorf("field %s: embedded pointer to unexported struct with encrypted fields", sf.Name)
+			}
+			continue
+		}
+		if sf.Tag.Get("encrypt") == "true" {
+			e, err := cryptTagged(f, fn)
+			if err != nil {
+				return fmt.Errorf("field %s: %s", sf.Name, err)
+			}
+			s.Field(i).Set(e)
+			continue
+		}
+		e, err := copyCryptValue(f, fn)
+		if err != nil {
+			return err
+		}
+		s.Field(i).Set(e)
+	}
+	return nil
+}
+
+// hasCryptFields checks if the type might have tagged fields, interfaces might hold any value
+func hasCryptFields(t reflect.Type, visited map[reflect.Type]bool) bool {
+	if visited[t] {
+		return false
+	}
+	visited[t] = true
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return hasCryptFields(t.Elem(), visited)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.Tag.Get("encrypt") == "true" || hasCryptFields(sf.Type, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cryptTagged returns copy of the tagged string, *string or []string field with fn applied to
+// its strings. Tagged fields of the other types are an error, so they are never sent in plain.
+func cryptTagged(v reflect.Value, fn func(string) (string, error)) (reflect.Value, error) {
+	switch {
+	case v.Kind() == reflect.String:
+		if v.String() == "" {
+			return v, nil
+		}
+		out, err := fn(v.String())
+		if err != nil {
+			return v, err
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.SetString(out)
+		return c, nil
+	case v.Kind() == reflect.Ptr && v.Type().Elem().Kind() == reflect.String:
+		if v.IsNil() {
+			return v, nil
+		}
+		e, err := cryptTagged(v.Elem(), fn)
+		if err != nil {
+			return v, err
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(e)
+		return p, nil
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		if v.IsNil() {
+			return v, nil
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			e, err := cryptTagged(v.Index(i), fn)
+			if err != nil {
+				return v, err
+			}
+			c.Index(i).Set(e)
+		}
+		return c, nil
+	}
+	return v, fmt.Errorf("encrypt tag is not supported on %s", v.Type())
+}
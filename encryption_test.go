@@ -0,0 +1,100 @@
+package goboot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type prefixKMS struct{}
+
+func (prefixKMS) Encrypt(plaintext string) (string, error) {
+	return "enc:" + plaintext, nil
+}
+
+func (prefixKMS) Decrypt(ciphertext string) (string, error) {
+	return strings.TrimPrefix(ciphertext, "enc:"), nil
+}
+
+type cryptIdentity struct {
+	SSN string `json:"ssn" encrypt:"true"`
+}
+
+type CryptContact struct {
+	Phone *string `json:"phone" encrypt:"true"`
+}
+
+type cryptOuter struct {
+	cryptIdentity
+	CryptContact
+	Name string `json:"name"`
+}
+
+type cryptOuterPtr struct {
+	*cryptIdentity
+	Name string `json:"name"`
+}
+
+func TestEncryptFields(t *testing.T) {
+	phone := "555"
+	in := cryptOuter{cryptIdentity{SSN: "123"}, CryptContact{Phone: &phone}, "a"}
+	out, err := EncryptFields(in, prefixKMS{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := out.(cryptOuter)
+	if got.SSN != "enc:123" {
+		t.Errorf("embedded unexported struct field SSN = %q, want enc:123", got.SSN)
+	}
+	if got.Phone == nil || *got.Phone != "enc:555" {
+		t.Errorf("embedded struct field Phone = %v, want enc:555", got.Phone)
+	}
+	if got.Name != "a" {
+		t.Errorf("untagged field Name = %q, want a", got.Name)
+	}
+	if in.SSN != "123" || phone != "555" {
+		t.Errorf("original value modified: %+v", in)
+	}
+
+	back, err := DecryptFields(got, prefixKMS{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := back.(cryptOuter); d.SSN != "123" || *d.Phone != "555" {
+		t.Errorf("decrypted = %+v, want the original", d)
+	}
+}
+
+func TestEncryptFieldsNested(t *testing.T) {
+	in := map[string][]*cryptOuter{"x": {{cryptIdentity: cryptIdentity{SSN: "1"}}}}
+	out, err := EncryptFields(in, prefixKMS{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]*cryptOuter{"x": {{cryptIdentity: cryptIdentity{SSN: "enc:1"}}}}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("EncryptFields() = %+v, want %+v", out, want)
+	}
+}
+
+func TestEncryptFieldsUnsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{"embedded unexported pointer", cryptOuterPtr{cryptIdentity: &cryptIdentity{SSN: "123"}}},
+		{"tagged int", struct {
+			PIN int `encrypt:"true"`
+		}{1234}},
+	}
+	for _, tt := range tests {
+		if _, err := EncryptFields(tt.v, prefixKMS{}); err == nil {
+			t.Errorf("%s: EncryptFields() succeeded, want error", tt.name)
+		}
+	}
+
+	// nil embedded pointer has no fields in the JSON
+	if _, err := EncryptFields(cryptOuterPtr{Name: "a"}, prefixKMS{}); err != nil {
+		t.Errorf("nil embedded pointer: EncryptFields() = %v, want no error", err)
+	}
+}
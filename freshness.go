@@ -0,0 +1,70 @@
+// Package goboot containing the request freshness check. Requests must carry the time they were
+// made and stale ones are rejected, which bounds the window a captured request can be replayed in.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrStaleRequest error for the request with missing timestamp or outside the allowed skew
+var ErrStaleRequest = &Error{"stale_request", 400, "Stale request", "Request timestamp is missing or outside of the allowed clock skew"}
+
+// FreshnessConfig configures the request freshness check. Header is the request timestamp header,
+// Date by default, with HTTP date or unix seconds value. MaxSkew is the allowed difference from
+// the server time in either direction to account for the clock drift, 5 minutes by default.
+type FreshnessConfig struct {
+	Header  string
+	MaxSkew time.Duration
+}
+
+// FreshnessHandler middleware to reject the requests with timestamp outside of the allowed skew
+// with 400 Bad Request
+func FreshnessHandler(ctx context.Context, cfg FreshnessConfig) func(http.Handler) http.Handler {
+	if cfg.Header == "" {
+		cfg.Header = "Date"
+	}
+	if cfg.MaxSkew <= 0 {
+		cfg.MaxSkew = 5 * time.Minute
+	}
+
+	m := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			t, ok := requestTime(r.Header.Get(cfg.Header))
+			if !ok {
+				WriteError(w, ErrStaleRequest)
+				return
+			}
+			skew := time.Since(t)
+			if skew < 0 {
+				skew = -skew
+			}
+			if skew > cfg.MaxSkew {
+				WriteError(w, ErrStaleRequest)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+
+	return m
+}
+
+// requestTime parses the HTTP date or unix seconds timestamp
+func requestTime(v string) (time.Time, bool) {
+	if v == "" {
+		return time.Time{}, false
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return t, true
+	}
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(sec, 0), true
+	}
+	return time.Time{}, false
+}
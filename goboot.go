@@ -39,9 +39,18 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/julienschmidt/httprouter"
@@ -57,6 +66,15 @@ type body struct {
 type params struct {
 	Key string
 }
+type route struct {
+	Key string
+}
+type idempotentDelete struct {
+	Key string
+}
+type transforms struct {
+	Key string
+}
 
 // Body key for request body
 var Body = body{Key: "Body"}
@@ -67,6 +85,18 @@ var Params = params{Key: "Params"}
 // SessionUserKey key for context
 var SessionUserKey = sessionUser{Key: "SessionUser"}
 
+// routeKey key for the matched route pattern
+var routeKey = route{Key: "Route"}
+
+// idempotentDeleteKey key to mark the idempotent delete routes
+var idempotentDeleteKey = idempotentDelete{Key: "IdempotentDelete"}
+
+// transformsKey key for the response transforms of the route
+var transformsKey = transforms{Key: "Transforms"}
+
+// RequestIDHeader header carrying the request id across the services
+const RequestIDHeader = "X-Request-ID"
+
 // Errors represents json errors
 type Errors struct {
 	Errors []*Error `json:"errors"`
@@ -95,12 +125,19 @@ var (
 	UnAuthorized = &Error{"un_authorized", 401, "Request UnAuthorized", "Request must be authorized"}
 	// Forbidden resource not found error
 	Forbidden = &Error{"forbidden", 403, "Request Forbidden", "Request Forbidden"}
-	// ErrNotFound resource not found error
+	// ErrNotFound resource not found error, for the single resource only. List endpoints
+	// respond with empty list using WriteEmptyList when nothing matches.
 	ErrNotFound = &Error{"not_found", 404, "Not found", "Data not found"}
 	// ErrBadRequest bad request error
 	ErrBadRequest = &Error{"bad_request", 400, "Bad request", "Request body is not well-formed. It must be JSON."}
+	// ErrUnsupportedVersion unknown request schema version error
+	ErrUnsupportedVersion = &Error{"unsupported_version", 400, "Unsupported version", "Request schema version is not supported"}
+	// ErrPayloadTooLarge request body too large error
+	ErrPayloadTooLarge = &Error{"payload_too_large", 413, "Payload Too Large", "Request body is too large"}
 	// ErrUnsupportedMediaType error
 	ErrUnsupportedMediaType = &Error{"not_supported", 405, "Not supported", "Unsupported media type"}
+	// ErrTooManyRequests rate limit exceeded error
+	ErrTooManyRequests = &Error{"too_many_requests", 429, "Too Many Requests", "Rate limit exceeded. Retry later."}
 	// ErrInternalServer error to represent server errors
 	ErrInternalServer = &Error{"internal_server_error", 500, "Internal Server Error", "Something went wrong."}
 )
@@ -108,11 +145,28 @@ var (
 // Router wraps httprouter.Router, which is non-compatible with http.Handler to make it
 // compatible by implementing http.Handler into a httprouter.Handler function.
 type Router struct {
-	r              *httprouter.Router
-	Ctx            context.Context
-	AllowedOrigins string
-	AllowedMethods string
-	AllowedHeaders string
+	r                 *httprouter.Router
+	Ctx               context.Context
+	AllowedOrigins    string
+	AllowedMethods    string
+	AllowedHeaders    string
+	DefaultVersion    string
+	cache             *responseCache
+	deprecations      *deprecationStats
+	deprecationEvents atomic.Value
+	hosts             []*hostRouter
+	authStrategies    []authStrategy
+	preprocessor      func(http.ResponseWriter, *http.Request) bool
+	auditSink         func(AuditEvent)
+	contractSink      func(string, ContractExample)
+	active            int32
+	parent            *Router
+}
+
+// hostRouter router for the requests to the host, host can be a wildcard like *.example.com
+type hostRouter struct {
+	host   string
+	router *Router
 }
 
 // DefaultRouter returns new go.Router with default settings
@@ -127,8 +181,62 @@ func DefaultRouter(ctx context.Context) *Router {
 	return ar
 }
 
+// Host returns the router for the routes scoped to the host, requests to other hosts are served
+// by the default router. Host can be a wildcard like *.example.com to match any subdomain.
+func (ar *Router) Host(host string) *Router {
+	host = strings.ToLower(host)
+	for _, hr := range ar.hosts {
+		if hr.host == host {
+			return hr.router
+		}
+	}
+
+	hr := &hostRouter{host: host, router: DefaultRouter(ar.Ctx)}
+	hr.router.AllowedOrigins = ar.AllowedOrigins
+	hr.router.AllowedMethods = ar.AllowedMethods
+	hr.router.AllowedHeaders = ar.AllowedHeaders
+	hr.router.parent = ar
+	ar.hosts = append(ar.hosts, hr)
+	return hr.router
+}
+
+// routerForHost returns the router for the request host, exact host match takes precedence
+// over the wildcards
+func (ar *Router) routerForHost(req *http.Request) *Router {
+	host := strings.ToLower(req.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	for _, hr := range ar.hosts {
+		if hr.host == host {
+			return hr.router
+		}
+	}
+	for _, hr := range ar.hosts {
+		if strings.HasPrefix(hr.host, "*.") && strings.HasSuffix(host, hr.host[1:]) {
+			return hr.router
+		}
+	}
+	return nil
+}
+
+// SetPreprocessor sets the function called at the very start of ServeHTTP, before CORS and
+// routing, for checks like IP blocklist. Returning false aborts the request, so preprocessor
+// must write the response itself.
+func (ar *Router) SetPreprocessor(fn func(http.ResponseWriter, *http.Request) bool) {
+	ar.preprocessor = fn
+}
+
 //ServeHTTP handler function that takes care of headers
 func (ar *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if ar.preprocessor != nil && !ar.preprocessor(w, req) {
+		return
+	}
+	if hr := ar.routerForHost(req); hr != nil {
+		hr.ServeHTTP(w, req)
+		return
+	}
+
 	if ar.AllowedOrigins == "*" {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 	} else {
@@ -139,7 +247,8 @@ func (ar *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			if strings.Contains(ar.AllowedOrigins, origin) {
 				w.Header().Set("Access-Control-Allow-Origin", origin)
 			} else {
-				WriteError(w, Forbidden)
+				// rejected before reaching the handlers, respond in the same shape as they do
+				writeAPIError(w, Forbidden)
 				return
 			}
 		}
@@ -156,33 +265,98 @@ func (ar *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 // Get wraps httprouter's GET function
 func (ar *Router) Get(path string, handler http.Handler) {
-	ar.r.GET(path, wrapHandler(ar.Ctx, handler))
+	ar.r.GET(path, ar.wrapHandler(path, handler))
+}
+
+// GetWithTransform wraps httprouter's GET function, transform runs on the APIResponse of the
+// handler before it's written, e.g. to redact the fields on the public routes
+func (ar *Router) GetWithTransform(path string, transform func(*APIResponse, *http.Request), handler http.Handler) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fns, _ := r.Context().Value(transformsKey).([]func(*APIResponse, *http.Request))
+		fns = append(fns[:len(fns):len(fns)], transform)
+		b := context.WithValue(r.Context(), transformsKey, fns)
+		handler.ServeHTTP(w, r.WithContext(b))
+	})
+	ar.r.GET(path, ar.wrapHandler(path, h))
 }
 
 // Post wraps httprouter's POST function
 func (ar *Router) Post(path string, handler http.Handler) {
-	ar.r.POST(path, wrapHandler(ar.Ctx, handler))
+	ar.r.POST(path, ar.wrapHandler(path, handler))
 }
 
 // Put wraps httprouter's PUT function
 func (ar *Router) Put(path string, handler http.Handler) {
-	ar.r.PUT(path, wrapHandler(ar.Ctx, handler))
+	ar.r.PUT(path, ar.wrapHandler(path, handler))
 }
 
 // Delete wraps httprouter's DELETE function
 func (ar *Router) Delete(path string, handler http.Handler) {
-	ar.r.DELETE(path, wrapHandler(ar.Ctx, handler))
+	ar.r.DELETE(path, ar.wrapHandler(path, handler))
+}
+
+// DeleteIdempotent wraps httprouter's DELETE function for the idempotent deletes. Deleting already
+// deleted resource, signaled by the handler with AlreadyDeletedResponse, responds with 204 same as
+// the first delete instead of 404, so the retried deletes don't fail.
+func (ar *Router) DeleteIdempotent(path string, handler http.Handler) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := context.WithValue(r.Context(), idempotentDeleteKey, true)
+		handler.ServeHTTP(w, r.WithContext(b))
+	})
+	ar.r.DELETE(path, ar.wrapHandler(path, h))
+}
+
+// PostVersioned wraps httprouter's POST function dispatching to the handler of the request schema
+// version from the X-Schema-Version header, falling back to the router DefaultVersion when header
+// is missing. Unknown versions are rejected with 400.
+func (ar *Router) PostVersioned(path string, handlers map[string]http.Handler) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version := r.Header.Get(SchemaVersionHeader)
+		if version == "" {
+			version = ar.DefaultVersion
+		}
+		w.Header().Add("Vary", SchemaVersionHeader)
+		handler, ok := handlers[version]
+		if !ok {
+			WriteError(w, ErrUnsupportedVersion)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+	ar.r.POST(path, ar.wrapHandler(path, h))
+}
+
+// ActiveRequests returns the number of requests being handled by the router and its host routers,
+// readiness checks use it to report draining while the requests are still in flight
+func (ar *Router) ActiveRequests() int {
+	n := int(atomic.LoadInt32(&ar.active))
+	for _, hr := range ar.hosts {
+		n += hr.router.ActiveRequests()
+	}
+	return n
 }
 
 // wrapHandler wraps http.Handler middleware function inside httprouter.Handle
-func wrapHandler(ctx context.Context, h http.Handler) httprouter.Handle {
+func (ar *Router) wrapHandler(path string, h http.Handler) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		atomic.AddInt32(&ar.active, 1)
+		defer atomic.AddInt32(&ar.active, -1)
+
 		//instead of passing extra params to handler function use context
+		c := context.WithValue(r.Context(), routeKey, path)
 		if ps != nil {
-			ctxParams := context.WithValue(r.Context(), Params, ps)
-			r = r.WithContext(ctxParams)
+			c = context.WithValue(c, Params, ps)
+		}
+		r = r.WithContext(c)
+		handler := h
+		if sink := ar.contractCapture(); sink != nil && sampleRequest(r, ContractSampleRate) {
+			handler = captureContract(sink, h)
+		}
+		if sink := ar.audit(); sink != nil && isMutating(r.Method) {
+			serveAudited(sink, w, r, ps, handler)
+			return
 		}
-		h.ServeHTTP(w, r)
+		handler.ServeHTTP(w, r)
 	}
 }
 
@@ -194,9 +368,25 @@ var ErrNotRecognized = errors.New("not recognized")
 
 // APIResponse response data representation for API
 type APIResponse struct {
-	Error  string      `json:"error,omitempty"`
-	Status string      `json:"status,omitempty"`
-	Data   interface{} `json:"data,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+	Status string                 `json:"status,omitempty"`
+	Data   interface{}            `json:"data,omitempty"`
+	Meta   map[string]interface{} `json:"meta,omitempty"`
+}
+
+// SchemaVersionHeader header used by the clients to select the request schema version
+const SchemaVersionHeader = "X-Schema-Version"
+
+// EnvelopeVersionHeader header used by the clients to select the response envelope version
+const EnvelopeVersionHeader = "X-Envelope-Version"
+
+// envelopes response envelopes by version, APIResponse itself is the default envelope
+var envelopes = map[string]func(APIResponse) interface{}{}
+
+// RegisterEnvelope registers the response envelope for the version. Clients select it with the
+// X-Envelope-Version header so the envelope can evolve without breaking the old clients.
+func RegisterEnvelope(version string, envelope func(APIResponse) interface{}) {
+	envelopes[version] = envelope
 }
 
 // Write - Reponse interface implementation
@@ -204,6 +394,30 @@ func (res APIResponse) Write(w http.ResponseWriter, r *http.Request) {
 	if res.Status == "ERROR" {
 		log.Printf("[ERROR][API][PATH: %s]:: Error handling request. ERROR: %s. User agent: %s", r.RequestURI, res.Error, r.Header.Get("User-Agent"))
 	}
+	res.Meta = responseMeta(r, res.Meta)
+	if fns, ok := r.Context().Value(transformsKey).([]func(*APIResponse, *http.Request)); ok {
+		for _, transform := range fns {
+			transform(&res, r)
+		}
+	}
+	res.Data = responseValue(r, res.Data)
+	if res.Meta != nil {
+		res.Meta, _ = responseValue(r, res.Meta).(map[string]interface{})
+	}
+	if res.Status == "OK" && r.Method != "GET" && Prefer(r, "return") == "minimal" {
+		w.Header().Set("Preference-Applied", "return=minimal")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	// response varies by the envelope version even when the header is absent, so caches don't
+	// serve the default envelope to the clients asking for another one
+	w.Header().Add("Vary", EnvelopeVersionHeader)
+	if v := r.Header.Get(EnvelopeVersionHeader); v != "" {
+		if envelope, ok := envelopes[v]; ok {
+			WriteJSON(w, envelope(res))
+			return
+		}
+	}
 	WriteJSON(w, res)
 }
 
@@ -222,6 +436,60 @@ func ErrorResponse(err error) APIResponse {
 	return APIResponse{Error: err.Error(), Status: "ERROR", Data: nil}
 }
 
+// deleteResponse response of the delete handlers
+type deleteResponse struct {
+	alreadyGone bool
+}
+
+// Write - Reponse interface implementation, responds with 204 No Content unless resource was
+// already deleted and the route is not registered with DeleteIdempotent
+func (res deleteResponse) Write(w http.ResponseWriter, r *http.Request) {
+	if idempotent, _ := r.Context().Value(idempotentDeleteKey).(bool); res.alreadyGone && !idempotent {
+		WriteError(w, ErrNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeletedResponse creates response for the successfully deleted resource
+func DeletedResponse() Response {
+	return deleteResponse{}
+}
+
+// AlreadyDeletedResponse creates response for the resource that was already deleted
+func AlreadyDeletedResponse() Response {
+	return deleteResponse{alreadyGone: true}
+}
+
+// RequestID returns the request id from the request header
+func RequestID(r *http.Request) string {
+	return r.Header.Get(RequestIDHeader)
+}
+
+// Prefer returns the value of the preference from the Prefer request header (RFC 7240) e.g.
+// minimal for return=minimal. Preference without value like respond-async returns its name
+// and unset preference returns empty string. APIResponse honors return=minimal for
+// the successful non GET requests by responding with 204 No Content.
+func Prefer(r *http.Request, key string) string {
+	for _, header := range r.Header["Prefer"] {
+		for _, pref := range strings.Split(header, ",") {
+			// preference parameters after ; are ignored
+			pref = strings.TrimSpace(strings.Split(pref, ";")[0])
+			name, value := pref, ""
+			if i := strings.Index(pref, "="); i >= 0 {
+				name, value = strings.TrimSpace(pref[:i]), strings.Trim(strings.TrimSpace(pref[i+1:]), `"`)
+			}
+			if strings.EqualFold(name, key) {
+				if value == "" {
+					return name
+				}
+				return value
+			}
+		}
+	}
+	return ""
+}
+
 // RequestBody returns the request body
 func RequestBody(r *http.Request) interface{} {
 	return r.Context().Value(Body)
@@ -230,17 +498,46 @@ func RequestBody(r *http.Request) interface{} {
 // SessionUserID returns user id of the current session
 func SessionUserID(r *http.Request) string {
 	if jwtClaims, ok := r.Context().Value(SessionUserKey).(jwt.MapClaims); ok {
-		return jwtClaims["uid"].(string)
+		uid, _ := jwtClaims["uid"].(string)
+		return uid
 	}
 	return ""
 }
 
+// RoutePattern returns the route pattern matched by the request e.g. /users/:uid
+func RoutePattern(r *http.Request) string {
+	pattern, _ := r.Context().Value(routeKey).(string)
+	return pattern
+}
+
+// LogFields returns the request context fields to be logged with errors
+func LogFields(r *http.Request) map[string]interface{} {
+	return map[string]interface{}{
+		"request_id": RequestID(r),
+		"user_id":    SessionUserID(r),
+		"route":      RoutePattern(r),
+		"method":     r.Method,
+		"client_ip":  ClientIP(r),
+	}
+}
+
 // UserRoles current user roles
 func UserRoles(r *http.Request) []string {
+	roles := make([]string, 0)
 	if jwtClaims, ok := r.Context().Value(SessionUserKey).(jwt.MapClaims); ok {
-		return jwtClaims["uid"].([]string)
+		switch claim := jwtClaims["roles"].(type) {
+		case []string:
+			roles = append(roles, claim...)
+		case []interface{}:
+			// roles decoded from the JSON token
+			for _, role := range claim {
+				if s, ok := role.(string); ok {
+					roles = append(roles, s)
+				}
+			}
+		}
 	}
-	return make([]string, 0)
+	return roles
 }
 
 // QueryParamByName returns the request param by name
@@ -254,10 +551,70 @@ func QueryParamsByName(name string, r *http.Request) []string {
 	return values[name]
 }
 
+// ExclusiveParams returns error if more than one of the named query params is present
+func ExclusiveParams(r *http.Request, names ...string) error {
+	query := r.URL.Query()
+	var present []string
+	for _, name := range names {
+		if _, ok := query[name]; ok {
+			present = append(present, name)
+		}
+	}
+	if len(present) > 1 {
+		return fmt.Errorf("query params %s are mutually exclusive", strings.Join(present, ", "))
+	}
+	return nil
+}
+
+// ExpandFields returns the related resources to inline in the response from the comma separated
+// expand query param, e.g. ?expand=author,comments. Param can be repeated.
+func ExpandFields(r *http.Request) map[string]bool {
+	fields := make(map[string]bool)
+	for _, v := range QueryParamsByName("expand", r) {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				fields[f] = true
+			}
+		}
+	}
+	return fields
+}
+
+// ValidateExpand returns error if the expand query param has relations not in allowed
+func ValidateExpand(r *http.Request, allowed ...string) error {
+	known := make(map[string]bool, len(allowed))
+	for _, a := range allowed {
+		known[a] = true
+	}
+	var unknown []string
+	for f := range ExpandFields(r) {
+		if !known[f] {
+			unknown = append(unknown, f)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("expand has unknown relations %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
 // ParamByName returns the request param by name
 func ParamByName(name string, r *http.Request) string {
-	params := r.Context().Value(Params).(httprouter.Params)
-	return params.ByName(name)
+	value, _ := ParamByNameOK(name, r)
+	return value
+}
+
+// ParamByNameOK returns the request param by name and whether the param was matched by the
+// route, so empty param value can be told apart from the param missing in the route.
+func ParamByNameOK(name string, r *http.Request) (string, bool) {
+	params, _ := r.Context().Value(Params).(httprouter.Params)
+	for _, p := range params {
+		if p.Key == name {
+			return p.Value, true
+		}
+	}
+	return "", false
 }
 
 //Authorize checks if given request is authorized
@@ -270,15 +627,196 @@ func Authorize(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// AuthorizeResources splits the resource ids into the ones the session user may access according
+// to checkFn and the forbidden ones, so bulk endpoints can serve the authorized subset. All ids
+// are forbidden if there's no session user.
+func AuthorizeResources(r *http.Request, ids []string, checkFn func(uid, id string) bool) ([]string, []string) {
+	authorized := make([]string, 0, len(ids))
+	forbidden := make([]string, 0)
+	uid := SessionUserID(r)
+	for _, id := range ids {
+		if uid != "" && checkFn(uid, id) {
+			authorized = append(authorized, id)
+		} else {
+			forbidden = append(forbidden, id)
+		}
+	}
+	return authorized, forbidden
+}
+
 // WriteJSON writes resource to the output stream as JSON data.
 func WriteJSON(w http.ResponseWriter, resource interface{}) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resource)
+	encodeJSON(w, resource)
+}
+
+// encodeJSON writes v as JSON to the response. Write failing because the client went away is
+// not a server error, so it's only logged at debug level.
+func encodeJSON(w http.ResponseWriter, v interface{}) {
+	err := json.NewEncoder(w).Encode(v)
+	if err == nil {
+		return
+	}
+	if isClientGone(err) {
+		DefaultLogBackend.Log(LevelDebug, "Client disconnected while writing response", map[string]interface{}{"error": err})
+		return
+	}
+	log.Printf("[ERROR] Error writing JSON response: %s", err)
+}
+
+// isClientGone returns whether the write error is a broken pipe or connection reset by the client
+func isClientGone(err error) bool {
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// StreamFlushInterval how often WriteStream flushes the written data to the client
+var StreamFlushInterval = 100 * time.Millisecond
+
+// WriteStream copies src to the response with the content type, flushing periodically so the
+// client gets the content as it's produced, and returns the bytes written. Copying stops with the
+// request context error when the client goes away, src implementing io.Closer is closed then to
+// unblock the pending read.
+func WriteStream(w http.ResponseWriter, r *http.Request, contentType string, src io.Reader) (int64, error) {
+	ctx := r.Context()
+	if c, ok := src.(io.Closer); ok {
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-ctx.Done():
+				c.Close()
+			case <-done:
+			}
+		}()
+	}
+	flusher, _ := w.(http.Flusher)
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	var written int64
+	buf := make([]byte, 32*1024)
+	lastFlush := time.Now()
+	for {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+
+		n, err := src.Read(buf)
+		if n > 0 {
+			m, werr := w.Write(buf[:n])
+			written += int64(m)
+			if werr != nil {
+				return written, werr
+			}
+			if flusher != nil && time.Since(lastFlush) >= StreamFlushInterval {
+				flusher.Flush()
+				lastFlush = time.Now()
+			}
+		}
+		if err == io.EOF {
+			if flusher != nil {
+				flusher.Flush()
+			}
+			return written, nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				// read failed as src was closed
+				return written, ctx.Err()
+			}
+			return written, err
+		}
+	}
+}
+
+// WriteJSONWithLastModified writes response with Last-Modified header set to modTime. It responds
+// with 304 Not Modified, without the body, if resource hasn't changed since If-Modified-Since.
+func WriteJSONWithLastModified(w http.ResponseWriter, r *http.Request, res APIResponse, modTime time.Time) {
+	if res.Status == "ERROR" || modTime.IsZero() {
+		res.Write(w, r)
+		return
+	}
+
+	// http dates have second precision
+	modTime = modTime.UTC().Truncate(time.Second)
+	w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+	if r.Method == "GET" || r.Method == "HEAD" {
+		if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modTime.After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	res.Write(w, r)
 }
 
 // WriteError writes error response
 func WriteError(w http.ResponseWriter, err *Error) {
+	setGRPCStatus(w, err)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(err.Status)
-	json.NewEncoder(w).Encode(Errors{[]*Error{err}})
+	encodeJSON(w, Errors{[]*Error{err}})
+}
+
+// writeAPIError writes the error status with the APIResponse error body, for the errors of the
+// router itself so clients parse them same as the handler errors
+func writeAPIError(w http.ResponseWriter, err *Error) {
+	setGRPCStatus(w, err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.Status)
+	encodeJSON(w, APIResponse{Status: "ERROR", Error: err.Detail})
+}
+
+// WriteEmptyList writes 200 OK response with empty data list, for the list endpoints with no
+// matching items. Response goes through APIResponse so it gets the same envelope and meta as
+// the non-empty lists.
+func WriteEmptyList(w http.ResponseWriter, r *http.Request) {
+	DataResponse(emptyList{}).Write(w, r)
+}
+
+// emptyList empty data list, non-nil value so the omitempty data field keeps it
+type emptyList struct{}
+
+func (emptyList) MarshalJSON() ([]byte, error) {
+	return []byte("[]"), nil
+}
+
+// WriteRateLimited writes 429 Too Many Requests error with the rate limit headers, reset is the
+// time when the limit resets
+func WriteRateLimited(w http.ResponseWriter, limit, remaining int, reset time.Time) {
+	retryAfter := int(math.Ceil(time.Until(reset).Seconds()))
+	if retryAfter < 0 {
+		retryAfter = 0
+	}
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	WriteError(w, ErrTooManyRequests)
+}
+
+// PropagateRetryAfter copies the Retry-After of the downstream 429 or 503 response, so clients back
+// off as long as the downstream asked. HTTP date is converted to seconds, as the downstream clock
+// may differ from the client one. It must be called before the response is written.
+func PropagateRetryAfter(w http.ResponseWriter, downstream *http.Response) {
+	if downstream == nil || (downstream.StatusCode != http.StatusTooManyRequests && downstream.StatusCode != http.StatusServiceUnavailable) {
+		return
+	}
+
+	v := strings.TrimSpace(downstream.Header.Get("Retry-After"))
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		return
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		// relative to the downstream clock when it sent the date
+		now := time.Now()
+		if date, err := http.ParseTime(downstream.Header.Get("Date")); err == nil {
+			now = date
+		}
+		retryAfter := int(math.Ceil(t.Sub(now).Seconds()))
+		if retryAfter < 0 {
+			retryAfter = 0
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	}
 }
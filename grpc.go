@@ -0,0 +1,87 @@
+// Package goboot containing the opt-in gRPC status codes of the error responses. Gateways fronting
+// the REST API for gRPC clients use the Grpc-Status header to translate the errors faithfully.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// gRPC status codes, see https://github.com/grpc/grpc/blob/master/doc/statuscodes.md
+const (
+	GRPCOK                 = 0
+	GRPCUnknown            = 2
+	GRPCInvalidArgument    = 3
+	GRPCNotFound           = 5
+	GRPCAlreadyExists      = 6
+	GRPCPermissionDenied   = 7
+	GRPCResourceExhausted  = 8
+	GRPCFailedPrecondition = 9
+	GRPCAborted            = 10
+	GRPCUnimplemented      = 12
+	GRPCInternal           = 13
+	GRPCUnavailable        = 14
+	GRPCUnauthenticated    = 16
+)
+
+// GRPCStatusEnabled enables the Grpc-Status header of the error responses written by WriteError
+var GRPCStatusEnabled = false
+
+var (
+	grpcMu    sync.RWMutex
+	grpcCodes = map[string]int{ErrUpdateRequired.ID: GRPCFailedPrecondition}
+)
+
+// RegisterGRPCStatus maps the error to the gRPC status code, errors not registered are mapped
+// based on their HTTP status
+func RegisterGRPCStatus(err *Error, code int) {
+	grpcMu.Lock()
+	defer grpcMu.Unlock()
+	grpcCodes[err.ID] = code
+}
+
+// GRPCStatus returns the gRPC status code of the error
+func GRPCStatus(err *Error) int {
+	grpcMu.RLock()
+	code, ok := grpcCodes[err.ID]
+	grpcMu.RUnlock()
+	if ok {
+		return code
+	}
+
+	switch err.Status {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+		return GRPCInvalidArgument
+	case http.StatusUnauthorized:
+		return GRPCUnauthenticated
+	case http.StatusForbidden:
+		return GRPCPermissionDenied
+	case http.StatusNotFound:
+		return GRPCNotFound
+	case http.StatusConflict:
+		return GRPCAborted
+	case http.StatusPreconditionFailed, http.StatusUpgradeRequired:
+		return GRPCFailedPrecondition
+	case http.StatusTooManyRequests:
+		return GRPCResourceExhausted
+	case http.StatusNotImplemented:
+		return GRPCUnimplemented
+	case http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return GRPCUnavailable
+	}
+	if err.Status >= 500 {
+		return GRPCInternal
+	}
+	return GRPCUnknown
+}
+
+// setGRPCStatus sets the gRPC status headers of the error if enabled
+func setGRPCStatus(w http.ResponseWriter, err *Error) {
+	if !GRPCStatusEnabled {
+		return
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(GRPCStatus(err)))
+	w.Header().Set("Grpc-Message", err.Title)
+}
@@ -0,0 +1,82 @@
+// Package goboot containing the response header deduplication. When several middleware set the
+// same header, values of list headers like Vary are merged and for headers like Cache-Control the
+// last value wins, so clients never get duplicated or conflicting values.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"net/http"
+	"strings"
+)
+
+var (
+	// MergedHeaders headers whose values are merged into a single deduplicated list
+	MergedHeaders = []string{"Vary"}
+	// LastValueHeaders headers for which only the last set value is kept
+	LastValueHeaders = []string{"Cache-Control"}
+)
+
+// DedupeHeaders merges MergedHeaders values and keeps the last value of LastValueHeaders
+func DedupeHeaders(h http.Header) {
+	for _, name := range MergedHeaders {
+		values := h[http.CanonicalHeaderKey(name)]
+		if len(values) == 0 {
+			continue
+		}
+
+		seen := make(map[string]bool)
+		var merged []string
+		for _, v := range values {
+			for _, item := range strings.Split(v, ",") {
+				item = strings.TrimSpace(item)
+				if item != "" && !seen[strings.ToLower(item)] {
+					seen[strings.ToLower(item)] = true
+					merged = append(merged, item)
+				}
+			}
+		}
+		h.Set(name, strings.Join(merged, ", "))
+	}
+
+	for _, name := range LastValueHeaders {
+		if values := h[http.CanonicalHeaderKey(name)]; len(values) > 1 {
+			h.Set(name, values[len(values)-1])
+		}
+	}
+}
+
+// HeaderDedupeHandler middleware to dedupe the response headers just before they are written
+func HeaderDedupeHandler(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&dedupeWriter{ResponseWriter: w}, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// dedupeWriter dedupes the headers before the headers are written
+type dedupeWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (dw *dedupeWriter) WriteHeader(status int) {
+	if !dw.wroteHeader {
+		dw.wroteHeader = true
+		DedupeHeaders(dw.Header())
+	}
+	dw.ResponseWriter.WriteHeader(status)
+}
+
+func (dw *dedupeWriter) Write(b []byte) (int, error) {
+	if !dw.wroteHeader {
+		dw.WriteHeader(http.StatusOK)
+	}
+	return dw.ResponseWriter.Write(b)
+}
+
+func (dw *dedupeWriter) Flush() {
+	if f, ok := dw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,104 @@
+// Package goboot containing the request locale resolution, so every handler formats the currency
+// and dates for the same locale.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"net/http"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+var (
+	// DefaultLocale locale of the requests without a valid one
+	DefaultLocale = "en-US"
+	// LocaleParam query param overriding the request locale
+	LocaleParam = "locale"
+	// LocaleClaim session user claim with the user locale
+	LocaleClaim = "locale"
+)
+
+// Locale returns the request locale as normalized BCP 47 tag, e.g. pt-BR or zh-Hant-TW. Locale is
+// taken from the locale query param, the session user locale claim and then the Accept-Language
+// header, in that order, the first valid one wins.
+func Locale(r *http.Request) string {
+	candidates := []string{QueryParamByName(LocaleParam, r)}
+	if jwtClaims, ok := r.Context().Value(SessionUserKey).(jwt.MapClaims); ok {
+		if claim, ok := jwtClaims[LocaleClaim].(string); ok {
+			candidates = append(candidates, claim)
+		}
+	}
+	candidates = append(candidates, acceptValues(r.Header.Get("Accept-Language"))...)
+
+	for _, c := range candidates {
+		if tag, ok := normalizeLocale(c); ok {
+			return tag
+		}
+	}
+	return DefaultLocale
+}
+
+// normalizeLocale returns the BCP 47 tag with the conventional casing, accepting _ as separator
+func normalizeLocale(s string) (string, bool) {
+	s = strings.Replace(strings.TrimSpace(s), "_", "-", -1)
+	if s == "" || s == "*" {
+		return "", false
+	}
+
+	subtags := strings.Split(s, "-")
+	extension := false
+	for i, st := range subtags {
+		if len(st) == 0 || len(st) > 8 || !isAlphanumeric(st) {
+			return "", false
+		}
+		switch {
+		case extension || (i > 0 && len(st) == 1):
+			// extension and private use subtags after the singleton
+			extension = true
+			subtags[i] = strings.ToLower(st)
+		case i == 0:
+			// language
+			if len(st) < 2 || !isAlpha(st) {
+				return "", false
+			}
+			subtags[i] = strings.ToLower(st)
+		case i == 1 && len(st) == 4 && isAlpha(st):
+			// script
+			subtags[i] = strings.ToUpper(st[:1]) + strings.ToLower(st[1:])
+		case len(st) == 2 && isAlpha(st), len(st) == 3 && isDigits(st):
+			// region
+			subtags[i] = strings.ToUpper(st)
+		default:
+			subtags[i] = strings.ToLower(st)
+		}
+	}
+	return strings.Join(subtags, "-"), true
+}
+
+func isAlpha(s string) bool {
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlphanumeric(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+// Package goboot containing the request scoped logger. Handlers log with Logger(r) and every line
+// has the request id, user id and route, so the logs of a request can be correlated.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// Log levels
+const (
+	LevelDebug = "DEBUG"
+	LevelInfo  = "INFO"
+	LevelWarn  = "WARN"
+	LevelError = "ERROR"
+)
+
+// LogLevel minimum level of the entries written by the standard log backend
+var LogLevel = LevelInfo
+
+// levelSeverity orders the log levels, unknown levels are always written
+var levelSeverity = map[string]int{LevelDebug: 0, LevelInfo: 1, LevelWarn: 2, LevelError: 3}
+
+// LogBackend writes the log entries, implement it to plug in the logging library of choice
+type LogBackend interface {
+	Log(level, msg string, fields map[string]interface{})
+}
+
+// DefaultLogBackend backend of the requests without LoggerHandler, it writes the entries of
+// LogLevel and above using the standard log package
+var DefaultLogBackend LogBackend = stdLogBackend{}
+
+type stdLogBackend struct{}
+
+func (stdLogBackend) Log(level, msg string, fields map[string]interface{}) {
+	if severity, ok := levelSeverity[level]; ok && severity < levelSeverity[LogLevel] {
+		return
+	}
+	log.Printf("[%s] %s %s", level, msg, formatFields(fields))
+}
+
+type logBackendKey struct {
+	Key string
+}
+
+var requestLogBackendKey = logBackendKey{Key: "LogBackend"}
+
+// LoggerHandler middleware to set the log backend of the request loggers
+func LoggerHandler(ctx context.Context, backend LogBackend) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			b := context.WithValue(r.Context(), requestLogBackendKey, backend)
+			next.ServeHTTP(w, r.WithContext(b))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+
+	return m
+}
+
+// RequestLogger logger with the request fields
+type RequestLogger struct {
+	backend LogBackend
+	fields  map[string]interface{}
+}
+
+// Logger returns the logger with the request LogFields. Fields are read when it's called, so
+// the user id is there when called after the auth middleware.
+func Logger(r *http.Request) *RequestLogger {
+	backend, ok := r.Context().Value(requestLogBackendKey).(LogBackend)
+	if !ok {
+		backend = DefaultLogBackend
+	}
+	return &RequestLogger{backend: backend, fields: LogFields(r)}
+}
+
+// With returns copy of the logger with the additional field
+func (l *RequestLogger) With(key string, value interface{}) *RequestLogger {
+	fields := make(map[string]interface{}, len(l.fields)+1)
+	for k, v := range l.fields {
+		fields[k] = v
+	}
+	fields[key] = value
+	return &RequestLogger{backend: l.backend, fields: fields}
+}
+
+// Debugf logs the message at debug level
+func (l *RequestLogger) Debugf(format string, args ...interface{}) {
+	l.backend.Log(LevelDebug, fmt.Sprintf(format, args...), l.fields)
+}
+
+// Infof logs the message at info level
+func (l *RequestLogger) Infof(format string, args ...interface{}) {
+	l.backend.Log(LevelInfo, fmt.Sprintf(format, args...), l.fields)
+}
+
+// Warnf logs the message at warn level
+func (l *RequestLogger) Warnf(format string, args ...interface{}) {
+	l.backend.Log(LevelWarn, fmt.Sprintf(format, args...), l.fields)
+}
+
+// Errorf logs the message at error level
+func (l *RequestLogger) Errorf(format string, args ...interface{}) {
+	l.backend.Log(LevelError, fmt.Sprintf(format, args...), l.fields)
+}
@@ -0,0 +1,84 @@
+// Package goboot containing the maintenance mode. During maintenance requests are answered with
+// 503 Service Unavailable and structured info about the maintenance window, so client apps can
+// show when the service is expected back.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MaintenanceInfo data of the maintenance response
+type MaintenanceInfo struct {
+	Until   time.Time `json:"until"`
+	Message string    `json:"message"`
+}
+
+// MaintenanceMode maintenance state which can be switched on and off at runtime
+type MaintenanceMode struct {
+	sync.RWMutex
+	enabled bool
+	info    MaintenanceInfo
+}
+
+// Enable turns on the maintenance mode expected to end at until
+func (mm *MaintenanceMode) Enable(until time.Time, message string) {
+	mm.Lock()
+	defer mm.Unlock()
+	mm.enabled = true
+	mm.info = MaintenanceInfo{Until: until, Message: message}
+}
+
+// Disable turns off the maintenance mode
+func (mm *MaintenanceMode) Disable() {
+	mm.Lock()
+	defer mm.Unlock()
+	mm.enabled = false
+}
+
+func (mm *MaintenanceMode) state() (bool, MaintenanceInfo) {
+	mm.RLock()
+	defer mm.RUnlock()
+	return mm.enabled, mm.info
+}
+
+// MaintenanceHandler middleware responds with the maintenance info while the maintenance
+// mode is enabled
+func MaintenanceHandler(ctx context.Context, mm *MaintenanceMode) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if enabled, info := mm.state(); enabled {
+				WriteMaintenance(w, r, info.Until, info.Message)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+
+	return m
+}
+
+// WriteMaintenance writes 503 Service Unavailable with the expected end time and message of the
+// maintenance as response data, Retry-After is set to the remaining time. Data is converted for
+// the request same as APIResponse data, so until is in the configured TimestampFormat.
+func WriteMaintenance(w http.ResponseWriter, r *http.Request, until time.Time, message string) {
+	if !until.IsZero() {
+		retryAfter := int(math.Ceil(time.Until(until).Seconds()))
+		if retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		}
+	}
+
+	data := responseValue(r, MaintenanceInfo{Until: until, Message: message})
+	res := APIResponse{Error: message, Status: "ERROR", Data: data}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	encodeJSON(w, res)
+}
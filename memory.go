@@ -0,0 +1,40 @@
+// Package goboot containing the sampled memory allocation logging, to find the allocation heavy
+// handlers in production without running the profiler.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"log"
+	"net/http"
+	"runtime"
+)
+
+// AllocationSampleRate measures the allocation of 1 in AllocationSampleRate requests, 0 disables
+// the measuring. runtime.ReadMemStats stops the world, so keep the rate low.
+var AllocationSampleRate = 0
+
+// AllocationHandler middleware to log the memory allocated by the sampled requests with their
+// route pattern. Allocation counters are process wide, so the numbers include the allocation of
+// the concurrent requests and are only meaningful aggregated over many samples.
+func AllocationHandler(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if AllocationSampleRate < 1 || !sampleRequest(r, AllocationSampleRate) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		next.ServeHTTP(w, r)
+		runtime.ReadMemStats(&after)
+
+		route := RoutePattern(r)
+		if route == "" {
+			route = r.URL.Path
+		}
+		log.Printf("Allocation:[%s] %s %d bytes %d objects\n", r.Method, route,
+			after.TotalAlloc-before.TotalAlloc, after.Mallocs-before.Mallocs)
+	}
+
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,75 @@
+// Package goboot containing the request scoped response fields. Middleware and handlers add
+// cross-cutting fields like rate limit info with AddResponseField and they are written in the
+// meta section of the APIResponse, so handlers don't have to know about them.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+type responseFields struct {
+	Key string
+}
+
+var responseFieldsKey = responseFields{Key: "ResponseFields"}
+
+// fields accumulates the response fields of the request
+type fields struct {
+	sync.Mutex
+	values map[string]interface{}
+}
+
+// ResponseFieldsHandler middleware to collect the response fields added during the request, it
+// must be the first middleware of the chain adding the fields.
+func ResponseFieldsHandler(ctx context.Context) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			f := &fields{values: make(map[string]interface{})}
+			b := context.WithValue(r.Context(), responseFieldsKey, f)
+			next.ServeHTTP(w, r.WithContext(b))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+
+	return m
+}
+
+// AddResponseField adds the field to the meta section of the response, it's a no-op if
+// ResponseFieldsHandler is not in the chain.
+func AddResponseField(r *http.Request, key string, value interface{}) {
+	f, ok := r.Context().Value(responseFieldsKey).(*fields)
+	if !ok {
+		return
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	f.values[key] = value
+}
+
+// responseMeta returns the response fields added during the request merged with meta
+func responseMeta(r *http.Request, meta map[string]interface{}) map[string]interface{} {
+	f, ok := r.Context().Value(responseFieldsKey).(*fields)
+	if !ok {
+		return meta
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	if len(f.values) == 0 {
+		return meta
+	}
+	merged := make(map[string]interface{}, len(meta)+len(f.values))
+	for k, v := range f.values {
+		merged[k] = v
+	}
+	// fields set by the handler on the response take precedence
+	for k, v := range meta {
+		merged[k] = v
+	}
+	return merged
+}
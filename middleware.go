@@ -6,13 +6,16 @@ package goboot
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"log"
+	"math/rand"
 	"net/http"
 	"reflect"
 	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -60,8 +63,7 @@ func JWTAuthHandler(ctx context.Context, secretAuthToken string, e ErrorHandler)
 			}
 			if next != nil {
 				if claims != nil {
-					b := context.WithValue(r.Context(), SessionUserKey, claims)
-					r = r.WithContext(b)
+					r = withSession(r, claims)
 				}
 				next.ServeHTTP(w, r)
 			}
@@ -93,10 +95,10 @@ func JSONBodyHandler(ctx context.Context, v interface{}) func(http.Handler) http
 			}
 
 			val := reflect.New(t).Interface()
-			err := json.NewDecoder(r.Body).Decode(val)
+			err := newJSONDecoder(r).Decode(val)
 			if err != nil {
 				log.Printf("[ERROR] Error decoding JSON data: %s", err)
-				WriteError(w, ErrBadRequest)
+				WriteBodyError(w, r, err)
 				return
 			}
 
@@ -113,6 +115,49 @@ func JSONBodyHandler(ctx context.Context, v interface{}) func(http.Handler) http
 	return m
 }
 
+// BodyLimitHandler limits the request body to maxBytes. Requests with larger body are rejected
+// with 413 Payload Too Large, either right away based on the Content-Length or by the body
+// reading handlers like JSONBodyHandler. Rejecting based on the Content-Length doesn't read the
+// body, so the clients sending Expect: 100-continue get the 413 without uploading the body.
+func BodyLimitHandler(ctx context.Context, maxBytes int64) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			b := context.WithValue(r.Context(), bodyLimitKey, maxBytes)
+			r = r.WithContext(b)
+			if r.ContentLength > maxBytes {
+				WriteBodyError(w, r, ErrBodyTooLarge)
+				return
+			}
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			}
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+
+	return m
+}
+
+// DeadlineHeaderHandler sets X-Deadline-Remaining-Ms response header with the time left when
+// the response was written, if the request context has a deadline. It must be appended after
+// the middleware setting the request timeout.
+func DeadlineHeaderHandler(ctx context.Context) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if deadline, ok := r.Context().Deadline(); ok {
+				w = &deadlineWriter{ResponseWriter: w, deadline: deadline}
+			}
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+
+	return m
+}
+
 // ResponseHandler handles the response from services and write it to the network output
 func ResponseHandler(f func(http.ResponseWriter, *http.Request) Response) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -125,13 +170,26 @@ func ResponseHandler(f func(http.ResponseWriter, *http.Request) Response) http.H
 // respond with a HTTP 500 error and log the panic. When our code panics in production
 // (make sure it should not but we can forget things sometimes) our application
 // will shutdown. We must catch panics, log them and keep the application running.
-// It's pretty easy with Go and our middleware system.
+// It's pretty easy with Go and our middleware system. Panic is logged with the request
+// context and passed to the ErrorHandler as *PanicError so the failing request can be reproduced.
 func RecoverHandler(ctx context.Context, e ErrorHandler) func(http.Handler) http.Handler {
 	m := func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
 				if rr := recover(); rr != nil {
-					log.Printf("PANIC: %s", debug.Stack())
+					if rr == http.ErrAbortHandler {
+						// response is deliberately aborted, let the server drop the connection
+						panic(rr)
+					}
+					if err, ok := rr.(error); ok && isClientGone(err) {
+						// client went away, there's no one to respond to
+						DefaultLogBackend.Log(LevelDebug, "Client disconnected while writing response", LogFields(r))
+						return
+					}
+
+					stack := debug.Stack()
+					fields := LogFields(r)
+					log.Printf("PANIC: %s %s", formatFields(fields), stack)
 
 					var err error
 					switch x := rr.(type) {
@@ -143,11 +201,12 @@ func RecoverHandler(ctx context.Context, e ErrorHandler) func(http.Handler) http
 						err = errors.New("Unknown panic")
 					}
 					if err != nil {
-						e.HandleError(r, err)
+						e.HandleError(r, &PanicError{Err: err, Fields: fields, Stack: stack})
 					}
 					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 				}
 			}()
+			next.ServeHTTP(w, r)
 		}
 
 		return http.HandlerFunc(fn)
@@ -156,6 +215,17 @@ func RecoverHandler(ctx context.Context, e ErrorHandler) func(http.Handler) http
 	return m
 }
 
+// PanicError error passed to the ErrorHandler for the recovered panic with the request context
+type PanicError struct {
+	Err    error
+	Fields map[string]interface{}
+	Stack  []byte
+}
+
+func (pe *PanicError) Error() string {
+	return pe.Err.Error()
+}
+
 // LoggingHandler middleware to log request/response
 func LoggingHandler(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
@@ -173,6 +243,38 @@ func LoggingHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(fn)
 }
 
+// LoggingConfig configures the request logging sampling
+type LoggingConfig struct {
+	// SampleRate logs 1 in SampleRate successful requests, 0 or 1 logs all of them
+	SampleRate int
+	// SlowThreshold requests slower than this are always logged, 0 disables it
+	SlowThreshold time.Duration
+}
+
+// SampledLoggingHandler middleware to log request/response same as LoggingHandler but only for the
+// sampled requests. Failed and slow requests are always logged. Sampling decision is based on the
+// request id so a request is either logged by every service or none.
+func SampledLoggingHandler(cfg LoggingConfig) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			sampled := sampleRequest(r, cfg.SampleRate)
+			t1 := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+			d := time.Since(t1)
+
+			slow := cfg.SlowThreshold > 0 && d > cfg.SlowThreshold
+			if sampled || slow || sw.statusCode() >= 400 {
+				log.Printf("Request:[%s] %q %d %v\n", r.Method, r.URL.String(), sw.statusCode(), d)
+			}
+		}
+
+		return http.HandlerFunc(fn)
+	}
+
+	return m
+}
+
 //ContentTypeHandler make sure content type is appplication/json for PUT/POST data
 func ContentTypeHandler(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
@@ -239,17 +341,13 @@ func extractAPIKeyFromAuthHeader(r *http.Request) (string, error) {
 }
 
 // extractTokenFromAuthHeader is a "TokenExtractor" that takes a give request and extracts
-// the JWT token from the Authorization header.
+// the JWT token from the Authorization header, parsed by BearerToken.
 func extractTokenFromAuthHeader(r *http.Request) (string, error) {
-	authHeaderParts, err := getAuthHeaderParts(r)
-	if err != nil {
-		return "", err
-	}
-	if len(authHeaderParts) != 2 || strings.ToLower(authHeaderParts[0]) != "bearer" {
+	token := BearerToken(r)
+	if token == "" {
 		return "", errors.New("Incorrect authorization header format. Invalid access token")
 	}
-
-	return authHeaderParts[1], nil
+	return token, nil
 }
 
 func getAuthHeaderParts(r *http.Request) ([]string, error) {
@@ -259,3 +357,142 @@ func getAuthHeaderParts(r *http.Request) ([]string, error) {
 	}
 	return strings.Split(authHeader, " "), nil
 }
+
+// sampleRequest decides if request is sampled at 1 in rate, using the request id when available
+func sampleRequest(r *http.Request, rate int) bool {
+	if rate <= 1 {
+		return true
+	}
+	id := RequestID(r)
+	if id == "" {
+		return rand.Intn(rate) == 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return h.Sum32()%uint32(rate) == 0
+}
+
+// statusWriter records the response status code written by the handlers
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+func (sw *statusWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (sw *statusWriter) statusCode() int {
+	if sw.status == 0 {
+		return http.StatusOK
+	}
+	return sw.status
+}
+
+type bodyLimit struct {
+	Key string
+}
+
+var bodyLimitKey = bodyLimit{Key: "BodyLimit"}
+
+// ErrBodyTooLarge same error as returned by http.MaxBytesReader when the body exceeds the
+// BodyLimitHandler limit, compare the error message as the reader returns its own instance
+var ErrBodyTooLarge = errors.New("http: request body too large")
+
+// WriteBodyError writes 413 Payload Too Large with the configured limit if body exceeded
+// the BodyLimitHandler limit, 400 Bad Request otherwise. Handlers reading the body themselves,
+// e.g. with BindJSONArray, respond to its error with it.
+func WriteBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	switch err {
+	case ErrNotJSONArray:
+		e := *ErrBadRequest
+		e.Detail = "Request body must be a JSON array"
+		WriteError(w, &e)
+		return
+	case ErrTooManyJSONArrayElements:
+		e := *ErrBadRequest
+		e.Detail = fmt.Sprintf("Request body array must not have more than %d elements", MaxJSONArrayElements)
+		WriteError(w, &e)
+		return
+	}
+	if err != nil && strings.HasPrefix(err.Error(), "json: unknown field ") {
+		// rejected in strict mode, tell the client which field it is
+		e := *ErrBadRequest
+		e.Detail = fmt.Sprintf("Request body has unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+		WriteError(w, &e)
+		return
+	}
+	if err == nil || err.Error() != ErrBodyTooLarge.Error() {
+		WriteError(w, ErrBadRequest)
+		return
+	}
+
+	e := *ErrPayloadTooLarge
+	if limit, ok := r.Context().Value(bodyLimitKey).(int64); ok {
+		e.Detail = fmt.Sprintf("Request body must not be larger than %d bytes", limit)
+	}
+	WriteError(w, &e)
+}
+
+// deadlineWriter sets the remaining deadline header just before the headers are written
+type deadlineWriter struct {
+	http.ResponseWriter
+	deadline    time.Time
+	wroteHeader bool
+}
+
+func (dw *deadlineWriter) WriteHeader(status int) {
+	if !dw.wroteHeader {
+		dw.wroteHeader = true
+		remaining := time.Until(dw.deadline)
+		if remaining < 0 {
+			remaining = 0
+		}
+		dw.Header().Set("X-Deadline-Remaining-Ms", strconv.FormatInt(int64(remaining/time.Millisecond), 10))
+	}
+	dw.ResponseWriter.WriteHeader(status)
+}
+
+func (dw *deadlineWriter) Write(b []byte) (int, error) {
+	if !dw.wroteHeader {
+		dw.WriteHeader(http.StatusOK)
+	}
+	return dw.ResponseWriter.Write(b)
+}
+
+func (dw *deadlineWriter) Flush() {
+	if f, ok := dw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// formatFields formats the fields as sorted key=value pairs
+func formatFields(fields map[string]interface{}) string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, fields[k]))
+	}
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,148 @@
+// Package goboot containing the pagination helpers. Cursors are opaque to the clients, they are
+// signed JSON values so clients can't craft a cursor to skip authorization boundaries.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// PageParam query param with the page number used in the pagination links
+var PageParam = "page"
+
+// IncludeTotalParam query param requesting the total count in the paginated responses
+var IncludeTotalParam = "includeTotal"
+
+// CursorSecret key used to sign the pagination cursors, it must be set by the application
+var CursorSecret []byte
+
+var (
+	// ErrInvalidCursor error for malformed or tampered cursor
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrNoCursorSecret error for encoding or verifying the cursors without CursorSecret set
+	ErrNoCursorSecret = errors.New("cursor secret is not set")
+)
+
+// Cursor returns the validated cursor query param, empty if it's not set
+func Cursor(r *http.Request) (string, error) {
+	token := QueryParamByName("cursor", r)
+	if token == "" {
+		return "", nil
+	}
+	if _, err := verifyCursor(token); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// EncodeCursor encodes v as signed JSON cursor, it fails if CursorSecret is not set as the
+// cursor could be forged
+func EncodeCursor(v interface{}) (string, error) {
+	if len(CursorSecret) == 0 {
+		return "", ErrNoCursorSecret
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(signCursor(payload)), nil
+}
+
+// DecodeCursor verifies the cursor signature and decodes it into v
+func DecodeCursor(token string, v interface{}) error {
+	data, err := verifyCursor(token)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// WritePaginationLinks sets RFC 5988 Link header with first, prev, next and last page links built
+// from the request URL, keeping the other query params as is. Pages start at 1.
+func WritePaginationLinks(w http.ResponseWriter, r *http.Request, page, pageSize, total int) {
+	if pageSize <= 0 {
+		return
+	}
+	last := (total + pageSize - 1) / pageSize
+	if last < 1 {
+		last = 1
+	}
+
+	links := []string{pageLink(r, 1, "first")}
+	if page > 1 {
+		links = append(links, pageLink(r, page-1, "prev"))
+	}
+	if page < last {
+		links = append(links, pageLink(r, page+1, "next"))
+	}
+	links = append(links, pageLink(r, last, "last"))
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
+
+// WritePaginatedFrom writes the items response with the total count in the meta section. Total is
+// computed using countFn only if the client asks for it with includeTotal=true, so the list
+// requests don't pay for the count query they don't use.
+func WritePaginatedFrom(w http.ResponseWriter, r *http.Request, items interface{}, countFn func() (int, error)) {
+	res := DataResponse(items)
+	if include, _ := strconv.ParseBool(QueryParamByName(IncludeTotalParam, r)); include {
+		total, err := countFn()
+		if err != nil {
+			log.Printf("[ERROR] Error counting the paginated items: %s", err)
+			WriteError(w, ErrInternalServer)
+			return
+		}
+		res.Meta = map[string]interface{}{"total": total}
+	}
+	res.Write(w, r)
+}
+
+func pageLink(r *http.Request, page int, rel string) string {
+	u := *r.URL
+	u.Scheme = "http"
+	if isHTTPS(r) {
+		u.Scheme = "https"
+	}
+	u.Host = r.Host
+	q := u.Query()
+	q.Set(PageParam, strconv.Itoa(page))
+	u.RawQuery = q.Encode()
+	return fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel)
+}
+
+func verifyCursor(token string) ([]byte, error) {
+	if len(CursorSecret) == 0 {
+		return nil, ErrNoCursorSecret
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(sig, signCursor(parts[0])) {
+		return nil, ErrInvalidCursor
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return data, nil
+}
+
+func signCursor(payload string) []byte {
+	mac := hmac.New(sha256.New, CursorSecret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
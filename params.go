@@ -0,0 +1,62 @@
+// Package goboot containing the validated path params. Params like email and phone are normalized
+// at the edge, so the same user is always looked up by the same value.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrInvalidEmail error for the malformed email param
+	ErrInvalidEmail = &Error{"invalid_email", 400, "Invalid email", "Email must be a plain email address"}
+	// ErrInvalidPhone error for the phone param which is not a valid international number
+	ErrInvalidPhone = &Error{"invalid_phone", 400, "Invalid phone", "Phone must be a valid international number"}
+)
+
+// DefaultPhoneCountryCode country calling code, like 1 or 44, of the phone numbers without one.
+// Leading 0 trunk prefix of such numbers is dropped. Numbers without country code are invalid if
+// it's not set.
+var DefaultPhoneCountryCode = ""
+
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// ParamEmailByName returns the email path param lower cased, ErrInvalidEmail if it's not a plain
+// email address, to be written with WriteError
+func ParamEmailByName(name string, r *http.Request) (string, *Error) {
+	value := strings.TrimSpace(ParamByName(name, r))
+	addr, err := mail.ParseAddress(value)
+	if err != nil || addr.Address != value {
+		// display names and comments are not allowed
+		return "", ErrInvalidEmail
+	}
+	return strings.ToLower(addr.Address), nil
+}
+
+// ParamPhoneByName returns the phone path param in E.164 format e.g. +14155550123, ErrInvalidPhone
+// if it's not a valid number. Spaces, dashes, dots and parentheses are ignored and 00 prefix is
+// taken as +.
+func ParamPhoneByName(name string, r *http.Request) (string, *Error) {
+	phone := strings.Map(func(c rune) rune {
+		switch c {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return c
+	}, ParamByName(name, r))
+
+	switch {
+	case strings.HasPrefix(phone, "+"):
+	case strings.HasPrefix(phone, "00"):
+		phone = "+" + phone[2:]
+	case DefaultPhoneCountryCode != "":
+		phone = "+" + DefaultPhoneCountryCode + strings.TrimPrefix(phone, "0")
+	}
+	if !e164.MatchString(phone) {
+		return "", ErrInvalidPhone
+	}
+	return phone, nil
+}
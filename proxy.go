@@ -0,0 +1,123 @@
+// Package goboot containing the helpers for services running behind a load balancer, where the
+// forwarded headers are trusted only when the request comes from the configured proxies.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// trustedProxies networks of the proxies allowed to set the forwarded headers, none by default
+var trustedProxies []*net.IPNet
+
+// ErrHTTPSRequired error for the requests not made over HTTPS
+var ErrHTTPSRequired = &Error{"https_required", 403, "HTTPS Required", "Request must be made over HTTPS"}
+
+// SetTrustedProxies trusts the forwarded headers of the requests coming from the given IPs or
+// CIDRs, like the load balancer network. By default forwarded headers are ignored, as any client
+// can set them.
+func SetTrustedProxies(proxies ...string) error {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, p := range proxies {
+		if !strings.Contains(p, "/") {
+			if strings.Contains(p, ":") {
+				p += "/128"
+			} else {
+				p += "/32"
+			}
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return err
+		}
+		nets = append(nets, n)
+	}
+	trustedProxies = nets
+	return nil
+}
+
+// RequireHTTPS middleware to enforce HTTPS based on X-Forwarded-Proto set by the load balancer.
+// Plain HTTP requests are redirected to HTTPS if redirect is true, otherwise rejected with 403.
+func RequireHTTPS(redirect bool) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if isHTTPS(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !redirect {
+				WriteError(w, ErrHTTPSRequired)
+				return
+			}
+
+			status := http.StatusPermanentRedirect
+			if r.Method == "GET" || r.Method == "HEAD" {
+				status = http.StatusMovedPermanently
+			}
+			http.Redirect(w, r, "https://"+r.Host+r.URL.RequestURI(), status)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+
+	return m
+}
+
+// ClientIP returns the IP of the client, taken from X-Forwarded-For header when the request
+// comes from the trusted proxy. Proxies append to the header, so it's the last address not of
+// a trusted proxy, the ones before it are set by the client.
+func ClientIP(r *http.Request) string {
+	ip := remoteIP(r)
+	if !fromTrustedProxy(r) {
+		return ip
+	}
+	hops := strings.Split(strings.Join(r.Header["X-Forwarded-For"], ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		ip = hop
+		if !isTrustedProxy(net.ParseIP(hop)) {
+			break
+		}
+	}
+	return ip
+}
+
+func isHTTPS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	if !fromTrustedProxy(r) {
+		return false
+	}
+	return strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// fromTrustedProxy checks if the request is coming from the trusted proxy
+func fromTrustedProxy(r *http.Request) bool {
+	return isTrustedProxy(net.ParseIP(remoteIP(r)))
+}
+
+func isTrustedProxy(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,112 @@
+// Package goboot containing the role based visibility of the response fields. Struct fields tagged
+// like `roles:"admin"` are dropped from the response data unless session user has one of the roles,
+// instead of constructing different DTOs per role.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// roleInfo tells if the type has fields with roles tag or interface values which might hold them
+type roleInfo struct {
+	roles   bool
+	dynamic bool
+}
+
+var (
+	roleInfoMu    sync.Mutex
+	roleInfoTypes = map[reflect.Type]roleInfo{}
+)
+
+// visible checks if the field is visible to the converter roles, roles tag is a comma separated
+// list of the roles allowed to see the field
+func (c jsonConverter) visible(sf reflect.StructField) bool {
+	tag := sf.Tag.Get("roles")
+	if c.roles == nil || tag == "" {
+		return true
+	}
+	for _, role := range strings.Split(tag, ",") {
+		if c.roles[strings.TrimSpace(role)] {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRoleFields checks if the value has any field with roles tag, type info is cached so only
+// the values of the interfaces are walked
+func hasRoleFields(v reflect.Value) bool {
+	if !v.IsValid() {
+		return false
+	}
+	info := typeRoleInfo(v.Type())
+	if info.roles {
+		return true
+	}
+	if !info.dynamic {
+		return false
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return !v.IsNil() && hasRoleFields(v.Elem())
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if hasRoleFields(v.Index(i)) {
+				return true
+			}
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			if hasRoleFields(v.MapIndex(k)) {
+				return true
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if sf := v.Type().Field(i); (sf.PkgPath == "" || sf.Anonymous) && hasRoleFields(v.Field(i)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func typeRoleInfo(t reflect.Type) roleInfo {
+	roleInfoMu.Lock()
+	defer roleInfoMu.Unlock()
+	info := typeRoleInfoLocked(t, map[reflect.Type]bool{})
+	roleInfoTypes[t] = info
+	return info
+}
+
+// typeRoleInfoLocked computes the type info, nested types aren't cached as results of the
+// recursive types are partial
+func typeRoleInfoLocked(t reflect.Type, visited map[reflect.Type]bool) roleInfo {
+	if info, ok := roleInfoTypes[t]; ok {
+		return info
+	}
+	if visited[t] {
+		return roleInfo{}
+	}
+	visited[t] = true
+
+	var info roleInfo
+	switch t.Kind() {
+	case reflect.Interface:
+		info.dynamic = true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		info = typeRoleInfoLocked(t.Elem(), visited)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			fi := typeRoleInfoLocked(sf.Type, visited)
+			info.roles = info.roles || sf.Tag.Get("roles") != "" || fi.roles
+			info.dynamic = info.dynamic || fi.dynamic
+		}
+	}
+	return info
+}
@@ -0,0 +1,66 @@
+package goboot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+type roleProduct struct {
+	Name string `json:"name"`
+	Cost int    `json:"cost" roles:"admin"`
+}
+
+type skuKey struct {
+	id int
+}
+
+func (k skuKey) MarshalText() ([]byte, error) {
+	return []byte("sku-" + strconv.Itoa(k.id)), nil
+}
+
+func TestResponseRoleFields(t *testing.T) {
+	p := roleProduct{Name: "a", Cost: 5}
+	tests := []struct {
+		name      string
+		data      interface{}
+		want      string
+		wantAdmin string
+	}{
+		{"struct", p, `{"name":"a"}`, `{"cost":5,"name":"a"}`},
+		{"pointer", &p, `{"name":"a"}`, `{"cost":5,"name":"a"}`},
+		{"slice", []roleProduct{p}, `[{"name":"a"}]`, `[{"cost":5,"name":"a"}]`},
+		{"map string", map[string]roleProduct{"x": p}, `{"x":{"name":"a"}}`, `{"x":{"cost":5,"name":"a"}}`},
+		{"map int", map[int]roleProduct{1: p}, `{"1":{"name":"a"}}`, `{"1":{"cost":5,"name":"a"}}`},
+		{"map uint", map[uint8]roleProduct{2: p}, `{"2":{"name":"a"}}`, `{"2":{"cost":5,"name":"a"}}`},
+		{"map text key", map[skuKey]roleProduct{{1}: p}, `{"sku-1":{"name":"a"}}`, `{"sku-1":{"cost":5,"name":"a"}}`},
+		{"interface", map[int]interface{}{1: []interface{}{p}}, `{"1":[{"name":"a"}]}`, `{"1":[{"cost":5,"name":"a"}]}`},
+	}
+	for _, tt := range tests {
+		for _, roles := range [][]string{{"user"}, {"admin"}} {
+			r := httptest.NewRequest("GET", "/products", nil)
+			claims := jwt.MapClaims{"uid": "1", "roles": roles}
+			r = r.WithContext(context.WithValue(r.Context(), SessionUserKey, claims))
+			w := httptest.NewRecorder()
+			DataResponse(tt.data).Write(w, r)
+
+			var res struct {
+				Data json.RawMessage `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Fatalf("%s: invalid response %s", tt.name, w.Body.String())
+			}
+			want := tt.want
+			if roles[0] == "admin" {
+				want = tt.wantAdmin
+			}
+			if string(res.Data) != want {
+				t.Errorf("%s with roles %v: data = %s, want %s", tt.name, roles, res.Data, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// Package goboot containing the request ordering by client sequence numbers. Clients like offline
+// sync send increasing X-Sequence-Number and requests out of order or already applied are
+// rejected, so retries never apply the operations out of order.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// SequenceHeader header with the client sequence number of the request
+const SequenceHeader = "X-Sequence-Number"
+
+var (
+	// ErrInvalidSequence missing or malformed sequence number error
+	ErrInvalidSequence = &Error{"invalid_sequence", 400, "Invalid sequence", "Request must have a valid X-Sequence-Number"}
+	// ErrDuplicateSequence already applied sequence number error
+	ErrDuplicateSequence = &Error{"duplicate_sequence", 409, "Duplicate sequence", "Request with this sequence number was already applied"}
+	// ErrOutOfOrderSequence sequence number too far ahead of the last one error
+	ErrOutOfOrderSequence = &Error{"out_of_order_sequence", 409, "Out of order sequence", "Request sequence number is out of order"}
+)
+
+// maxSequenceWindow sequence numbers tracked in SequenceState.Applied
+const maxSequenceWindow = 64
+
+// SequenceState sequence state of the client. Last is the highest reserved sequence number and
+// bit i of Applied is set if Last-i is reserved, so the numbers within the window can be reserved
+// in any order but only once.
+type SequenceState struct {
+	Last    int64
+	Applied uint64
+}
+
+// applied checks if the sequence number is reserved, numbers before the tracked ones are
+func (s SequenceState) applied(seq int64) bool {
+	if seq < 1 || s.Last-seq >= maxSequenceWindow {
+		return true
+	}
+	return seq <= s.Last && s.Applied&(1<<uint64(s.Last-seq)) != 0
+}
+
+// SequenceStore stores the sequence state of each client
+type SequenceStore interface {
+	// State returns the sequence state of the client, zero state if client was never seen
+	State(client string) (SequenceState, error)
+	// CompareAndSet sets the client sequence state to next only if it's still old
+	CompareAndSet(client string, old, next SequenceState) (bool, error)
+}
+
+// SequenceConfig configures the request ordering. Window is how far ahead of the sequence numbers
+// not applied yet request can be, 1 requires strictly consecutive numbers and larger window lets
+// the requests within it be applied in any order, up to 64. Every number must still be applied,
+// requests further ahead of a missing one are rejected until it is. ClientID identifies the
+// client, it defaults to the session user id.
+type SequenceConfig struct {
+	Store    SequenceStore
+	Window   int64
+	ClientID func(*http.Request) string
+}
+
+// SequenceHandler middleware to reject the duplicate and out of order requests of the client.
+// Sequence number is reserved before the handler runs and released if the request fails, so
+// the client can retry it.
+func SequenceHandler(ctx context.Context, cfg SequenceConfig, e ErrorHandler) func(http.Handler) http.Handler {
+	if cfg.Window < 1 {
+		cfg.Window = 1
+	}
+	if cfg.Window > maxSequenceWindow {
+		cfg.Window = maxSequenceWindow
+	}
+	if cfg.ClientID == nil {
+		cfg.ClientID = SessionUserID
+	}
+
+	m := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			client := cfg.ClientID(r)
+			seq, err := strconv.ParseInt(r.Header.Get(SequenceHeader), 10, 64)
+			if client == "" || err != nil || seq < 1 {
+				WriteError(w, ErrInvalidSequence)
+				return
+			}
+
+			state, err := cfg.Store.State(client)
+			if err != nil {
+				log.Printf("[ERROR] Error reading client sequence state: %s", err)
+				e.HandleError(r, err)
+				WriteError(w, ErrInternalServer)
+				return
+			}
+			if state.applied(seq) {
+				WriteError(w, ErrDuplicateSequence)
+				return
+			}
+			reserved, ok := reserveSequence(state, seq, cfg.Window)
+			if !ok {
+				WriteError(w, ErrOutOfOrderSequence)
+				return
+			}
+			if ok, err := cfg.Store.CompareAndSet(client, state, reserved); err != nil || !ok {
+				if err != nil {
+					log.Printf("[ERROR] Error saving client sequence state: %s", err)
+					e.HandleError(r, err)
+				}
+				// concurrent request of the client changed the state first
+				WriteError(w, ErrOutOfOrderSequence)
+				return
+			}
+
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+			if sw.statusCode() >= 300 {
+				releaseSequence(cfg.Store, client, seq)
+			}
+		}
+
+		return http.HandlerFunc(fn)
+	}
+
+	return m
+}
+
+// reserveSequence returns the state with the sequence number reserved, false if it's too far
+// ahead of the numbers not applied yet
+func reserveSequence(state SequenceState, seq, window int64) (SequenceState, bool) {
+	for n := seq - window; n > state.Last-maxSequenceWindow && n > 0; n-- {
+		if !state.applied(n) {
+			return state, false
+		}
+	}
+	if seq > state.Last {
+		state.Applied = state.Applied<<uint64(seq-state.Last) | 1
+		state.Last = seq
+		return state, true
+	}
+	state.Applied |= 1 << uint64(state.Last-seq)
+	return state, true
+}
+
+// releaseSequence clears the reserved sequence number, retrying if concurrent requests of the
+// client change the state meanwhile
+func releaseSequence(store SequenceStore, client string, seq int64) {
+	for i := 0; i < 3; i++ {
+		state, err := store.State(client)
+		if err != nil {
+			log.Printf("[ERROR] Error releasing client sequence number: %s", err)
+			return
+		}
+		if state.Last-seq >= maxSequenceWindow {
+			return
+		}
+		released := state
+		released.Applied &^= 1 << uint64(state.Last-seq)
+		ok, err := store.CompareAndSet(client, state, released)
+		if err != nil {
+			log.Printf("[ERROR] Error releasing client sequence number: %s", err)
+			return
+		}
+		if ok {
+			return
+		}
+	}
+	log.Printf("[ERROR] Error releasing client sequence number %d, state keeps changing", seq)
+}
+
+// MemorySequenceStore in-memory SequenceStore for a single instance service
+type MemorySequenceStore struct {
+	sync.Mutex
+	states map[string]SequenceState
+}
+
+// NewMemorySequenceStore returns empty in-memory sequence store
+func NewMemorySequenceStore() *MemorySequenceStore {
+	return &MemorySequenceStore{states: make(map[string]SequenceState)}
+}
+
+// State SequenceStore implementation
+func (s *MemorySequenceStore) State(client string) (SequenceState, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.states[client], nil
+}
+
+// CompareAndSet SequenceStore implementation
+func (s *MemorySequenceStore) CompareAndSet(client string, old, next SequenceState) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.states[client] != old {
+		return false, nil
+	}
+	s.states[client] = next
+	return true, nil
+}
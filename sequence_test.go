@@ -0,0 +1,56 @@
+package goboot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+type nopErrorHandler struct{}
+
+func (nopErrorHandler) HandleError(r *http.Request, err error) {}
+
+func TestSequenceHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		window int64
+		seqs   []int64
+		want   []int
+	}{
+		{"consecutive", 1, []int64{1, 2, 3}, []int{200, 200, 200}},
+		{"duplicate", 1, []int64{1, 2, 2, 1}, []int{200, 200, 409, 409}},
+		{"gap", 1, []int64{1, 3, 2, 3}, []int{200, 409, 200, 200}},
+		{"reordered within window", 5, []int64{1, 3, 2, 2, 3}, []int{200, 200, 200, 409, 409}},
+		{"too far ahead", 3, []int64{1, 5, 4}, []int{200, 409, 200}},
+		{"missing blocks window", 2, []int64{1, 3, 4, 2, 4}, []int{200, 200, 409, 200, 200}},
+		{"failed released", 1, []int64{1, -2, 3, 2, 3}, []int{200, 500, 409, 200, 200}},
+	}
+	for _, tt := range tests {
+		cfg := SequenceConfig{
+			Store:    NewMemorySequenceStore(),
+			Window:   tt.window,
+			ClientID: func(*http.Request) string { return "client" },
+		}
+		h := SequenceHandler(context.Background(), cfg, nopErrorHandler{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("fail") != "" {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}))
+		for i, seq := range tt.seqs {
+			target := "/sync"
+			if seq < 0 {
+				// handler fails, so the sequence number can be retried
+				seq, target = -seq, "/sync?fail=1"
+			}
+			r := httptest.NewRequest("POST", target, nil)
+			r.Header.Set(SequenceHeader, strconv.FormatInt(seq, 10))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.want[i] {
+				t.Errorf("%s: request %d with sequence %d status = %d, want %d", tt.name, i, seq, w.Code, tt.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Package goboot containing the signed URLs for time limited download or action links, like the
+// unsubscribe links sent by email. Signature covers the path, query and expiry of the URL.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrInvalidSignature error for the URL with missing or wrong signature
+	ErrInvalidSignature = errors.New("invalid URL signature")
+	// ErrSignatureExpired error for the signed URL past its expiry
+	ErrSignatureExpired = errors.New("URL signature expired")
+)
+
+// SignURL returns base URL with params, expires and signature query params valid for ttl
+func SignURL(base string, params map[string]string, secret []byte, ttl time.Duration) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return ""
+	}
+
+	q := u.Query()
+	for k, v := range params {
+		q.Set(k, v)
+	}
+	q.Del("signature")
+	q.Set("expires", strconv.FormatInt(time.Now().Add(ttl).Unix(), 10))
+	q.Set("signature", urlSignature(u.Path, q, secret))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// VerifySignedURL verifies the signature and expiry of the request URL signed using SignURL
+func VerifySignedURL(r *http.Request, secret []byte) error {
+	q := r.URL.Query()
+	sig, err := hex.DecodeString(q.Get("signature"))
+	if err != nil || len(sig) == 0 {
+		return ErrInvalidSignature
+	}
+	q.Del("signature")
+
+	expected, _ := hex.DecodeString(urlSignature(r.URL.Path, q, secret))
+	if !hmac.Equal(sig, expected) {
+		return ErrInvalidSignature
+	}
+	expires, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if time.Now().Unix() > expires {
+		return ErrSignatureExpired
+	}
+	return nil
+}
+
+// urlSignature signs the path and the query without the signature, url.Values.Encode sorts
+// the params so signature doesn't depend on their order
+func urlSignature(path string, q url.Values, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(path))
+	mac.Write([]byte("?"))
+	mac.Write([]byte(q.Encode()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
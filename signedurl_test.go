@@ -0,0 +1,60 @@
+package goboot
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestVerifySignedURL(t *testing.T) {
+	secret := []byte("secret")
+	valid := SignURL("https://api.example.com/unsubscribe?list=news", map[string]string{"uid": "42"}, secret, time.Hour)
+
+	tamper := func(key, value string) string {
+		u, _ := url.Parse(valid)
+		q := u.Query()
+		q.Set(key, value)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	withoutSignature := func() string {
+		u, _ := url.Parse(valid)
+		q := u.Query()
+		q.Del("signature")
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+
+	tests := []struct {
+		name   string
+		url    string
+		secret []byte
+		want   error
+	}{
+		{"valid", valid, secret, nil},
+		{"expired", SignURL("https://api.example.com/unsubscribe", nil, secret, -time.Minute), secret, ErrSignatureExpired},
+		{"wrong secret", valid, []byte("other"), ErrInvalidSignature},
+		{"tampered param", tamper("uid", "43"), secret, ErrInvalidSignature},
+		{"added param", tamper("admin", "true"), secret, ErrInvalidSignature},
+		{"extended expiry", tamper("expires", "99999999999"), secret, ErrInvalidSignature},
+		{"malformed signature", tamper("signature", "zz"), secret, ErrInvalidSignature},
+		{"missing signature", withoutSignature(), secret, ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.url, nil)
+		if got := VerifySignedURL(r, tt.secret); got != tt.want {
+			t.Errorf("%s: VerifySignedURL() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestVerifySignedURLPath(t *testing.T) {
+	secret := []byte("secret")
+	u, _ := url.Parse(SignURL("https://api.example.com/files/1", nil, secret, time.Hour))
+	u.Path = "/files/2"
+	r := httptest.NewRequest("GET", u.String(), nil)
+	if err := VerifySignedURL(r, secret); err != ErrInvalidSignature {
+		t.Errorf("VerifySignedURL() with changed path = %v, want %v", err, ErrInvalidSignature)
+	}
+}
@@ -0,0 +1,145 @@
+// Package goboot containing the multi-tenant helpers, tenant of the request and the per tenant
+// rate limiter so one noisy tenant can't exhaust the capacity for the others.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// TenantIDHeader header with the tenant id of the request
+const TenantIDHeader = "X-Tenant-ID"
+
+// TenantID returns the tenant id from the session user tid claim. For the requests without
+// session, X-Tenant-ID header is used only if set by the trusted proxy, see SetTrustedProxies.
+func TenantID(r *http.Request) string {
+	if jwtClaims, ok := r.Context().Value(SessionUserKey).(jwt.MapClaims); ok {
+		if tid, ok := jwtClaims["tid"].(string); ok && tid != "" {
+			return tid
+		}
+	}
+	if !fromTrustedProxy(r) {
+		return ""
+	}
+	return r.Header.Get(TenantIDHeader)
+}
+
+// RateLimit number of requests allowed per window
+type RateLimit struct {
+	Requests int
+	Window   time.Duration
+}
+
+type tenantWindow struct {
+	start time.Time
+	count int
+}
+
+// TenantRateLimiter fixed window rate limiter keyed on the tenant id. Limits can be changed at
+// runtime, tenants without their own limit use the default one.
+type TenantRateLimiter struct {
+	sync.Mutex
+	defaultLimit RateLimit
+	limits       map[string]RateLimit
+	windows      map[string]*tenantWindow
+	lastSweep    time.Time
+}
+
+// NewTenantRateLimiter returns rate limiter with the default limit
+func NewTenantRateLimiter(defaultLimit RateLimit) *TenantRateLimiter {
+	return &TenantRateLimiter{
+		defaultLimit: defaultLimit,
+		limits:       make(map[string]RateLimit),
+		windows:      make(map[string]*tenantWindow),
+	}
+}
+
+// SetDefaultLimit sets the limit of the tenants without their own limit
+func (l *TenantRateLimiter) SetDefaultLimit(limit RateLimit) {
+	l.Lock()
+	defer l.Unlock()
+	l.defaultLimit = limit
+}
+
+// SetLimit sets the limit of the tenant
+func (l *TenantRateLimiter) SetLimit(tenant string, limit RateLimit) {
+	l.Lock()
+	defer l.Unlock()
+	l.limits[tenant] = limit
+}
+
+// allow counts the tenant request and returns whether it's allowed with the limit, remaining
+// requests and the window reset time
+func (l *TenantRateLimiter) allow(tenant string) (bool, int, int, time.Time) {
+	l.Lock()
+	defer l.Unlock()
+	limit := l.limit(tenant)
+	now := time.Now()
+	l.sweep(now)
+	w := l.windows[tenant]
+	if w == nil || now.Sub(w.start) >= limit.Window {
+		w = &tenantWindow{start: now}
+		l.windows[tenant] = w
+	}
+	reset := w.start.Add(limit.Window)
+	if w.count >= limit.Requests {
+		return false, limit.Requests, 0, reset
+	}
+	w.count++
+	return true, limit.Requests, limit.Requests - w.count, reset
+}
+
+func (l *TenantRateLimiter) limit(tenant string) RateLimit {
+	if limit, ok := l.limits[tenant]; ok {
+		return limit
+	}
+	return l.defaultLimit
+}
+
+// sweep evicts the expired windows, at most once per default window
+func (l *TenantRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.defaultLimit.Window {
+		return
+	}
+	l.lastSweep = now
+	for tenant, w := range l.windows {
+		if now.Sub(w.start) >= l.limit(tenant).Window {
+			delete(l.windows, tenant)
+		}
+	}
+}
+
+// TenantRateLimitHandler middleware to rate limit the requests per tenant, requests over the limit
+// are rejected with 429 and the tenant rate limit headers. Requests without tenant are limited
+// per client IP with the default limit.
+func TenantRateLimitHandler(ctx context.Context, l *TenantRateLimiter) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			tenant := TenantID(r)
+			if tenant == "" {
+				// prefixed so it can't collide with the tenant ids
+				tenant = "ip:" + ClientIP(r)
+			}
+
+			ok, limit, remaining, reset := l.allow(tenant)
+			if !ok {
+				WriteRateLimited(w, limit, remaining, reset)
+				return
+			}
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+
+	return m
+}
@@ -0,0 +1,290 @@
+// Package goboot containing the timestamp format setting of the API responses, so the service
+// chooses how time.Time values are serialized instead of each model defining its own MarshalJSON.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeFormat format of the time.Time values in the API responses
+type TimeFormat int
+
+const (
+	// TimestampRFC3339 formats time as RFC3339 string, the encoding/json default
+	TimestampRFC3339 TimeFormat = iota
+	// TimestampUnixMillis formats time as milliseconds since Unix epoch
+	TimestampUnixMillis
+)
+
+// TimestampFormat format of the time.Time values of the APIResponse data and meta
+var TimestampFormat = TimestampRFC3339
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// jsonConverter converts values to the equivalent JSON values, formatting time.Time values as
+// UnixMillis when millis is set and dropping the struct fields not visible to the roles when
+// roles is not nil. Values implementing json.Marshaler or encoding.TextMarshaler are left as is,
+// so encoding/json marshals them same as without the converter.
+type jsonConverter struct {
+	millis bool
+	roles  map[string]bool
+}
+
+// responseValue converts the response data for the request, v is returned as is if there is
+// nothing to convert
+func responseValue(r *http.Request, v interface{}) interface{} {
+	if v == nil {
+		return v
+	}
+
+	c := jsonConverter{millis: TimestampFormat == TimestampUnixMillis}
+	if hasRoleFields(reflect.ValueOf(v)) {
+		c.roles = make(map[string]bool)
+		for _, role := range UserRoles(r) {
+			c.roles[role] = true
+		}
+	}
+	if !c.millis && c.roles == nil {
+		return v
+	}
+	return c.value(reflect.ValueOf(v))
+}
+
+func (c jsonConverter) value(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type() == timeType {
+		if !c.millis {
+			return v.Interface()
+		}
+		return v.Interface().(time.Time).UnixNano() / int64(time.Millisecond)
+	}
+	if v.Kind() == reflect.Ptr && v.Type().Elem() == timeType {
+		if v.IsNil() {
+			return nil
+		}
+		return c.value(v.Elem())
+	}
+	if m, ok := marshalerValue(v); ok {
+		return m
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return c.value(v.Elem())
+	case reflect.Struct:
+		m := make(map[string]interface{})
+		c.structFields(v, m)
+		return m
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			// []byte is encoded as base64 string
+			return v.Interface()
+		}
+		fallthrough
+	case reflect.Array:
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			s[i] = c.value(v.Index(i))
+		}
+		return s
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := make(map[string]interface{}, v.Len())
+		for _, k := range v.MapKeys() {
+			name, ok := mapKey(k)
+			if !ok {
+				// not a valid JSON key, left to encoding/json to report
+				return v.Interface()
+			}
+			m[name] = c.value(v.MapIndex(k))
+		}
+		return m
+	}
+	return v.Interface()
+}
+
+// structFields sets the struct fields into m following the encoding/json field rules,
+// embedded struct fields are promoted unless shadowed by the outer struct fields
+func (c jsonConverter) structFields(v reflect.Value, m map[string]interface{}) {
+	t := v.Type()
+	var embedded []reflect.Value
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" || !c.visible(sf) {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i:]
+		}
+
+		f := v.Field(i)
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != timeType {
+				if f.Kind() == reflect.Ptr {
+					if f.IsNil() {
+						continue
+					}
+					f = f.Elem()
+				}
+				embedded = append(embedded, f)
+				continue
+			}
+		}
+		if sf.PkgPath != "" {
+			// unexported field
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if hasOption(opts, "omitempty") && isEmptyValue(f) {
+			continue
+		}
+		if hasOption(opts, "string") && isQuotable(sf.Type) {
+			m[name] = quotedValue(f)
+			continue
+		}
+		m[name] = c.value(f)
+	}
+
+	for _, e := range embedded {
+		em := make(map[string]interface{})
+		c.structFields(e, em)
+		for k, val := range em {
+			if _, ok := m[k]; !ok {
+				m[k] = val
+			}
+		}
+	}
+}
+
+// marshalerValue returns the value implementing json.Marshaler or encoding.TextMarshaler, also
+// through the pointer receiver of addressable value as encoding/json does
+func marshalerValue(v reflect.Value) (interface{}, bool) {
+	if v.Kind() == reflect.Interface {
+		return nil, false
+	}
+	t := v.Type()
+	if t.Implements(marshalerType) || t.Implements(textMarshalerType) {
+		return v.Interface(), true
+	}
+	if v.CanAddr() {
+		pt := reflect.PtrTo(t)
+		if pt.Implements(marshalerType) || pt.Implements(textMarshalerType) {
+			return v.Addr().Interface(), true
+		}
+	}
+	return nil, false
+}
+
+// mapKey returns the JSON object key of the map key same as encoding/json, string keys are used
+// as is, encoding.TextMarshaler keys are marshaled and integer keys are formatted
+func mapKey(k reflect.Value) (string, bool) {
+	if k.Kind() == reflect.String {
+		return k.String(), true
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Ptr && k.IsNil() {
+			return "", true
+		}
+		b, err := tm.MarshalText()
+		return string(b), err == nil
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), true
+	}
+	return "", false
+}
+
+// hasOption checks the json tag options like ",omitempty,string" for the option
+func hasOption(opts, option string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// isQuotable checks if the ,string option applies to the field type, same as encoding/json it
+// applies to the string, number and bool fields or pointers to them, except marshalers
+func isQuotable(t reflect.Type) bool {
+	if t.Name() == "" && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Implements(marshalerType) || t.Implements(textMarshalerType) ||
+		reflect.PtrTo(t).Implements(marshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+// quotedValue returns the JSON encoding of the value as string, as the ,string option does
+func quotedValue(v reflect.Value) interface{} {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil
+	}
+	return string(b)
+}
+
+// isEmptyValue same as encoding/json omitempty check
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+// Package goboot containing the Server-Timing support. Handlers record named timing segments
+// like db or cache with RecordTiming and ServerTimingHandler writes them as Server-Timing header.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type serverTiming struct {
+	Key string
+}
+
+var serverTimingKey = serverTiming{Key: "ServerTiming"}
+
+// timings accumulates the durations of the segments in the order they were first recorded
+type timings struct {
+	sync.Mutex
+	names     []string
+	durations map[string]time.Duration
+}
+
+// ServerTimingHandler middleware to emit the timings recorded by the handlers using RecordTiming
+// as Server-Timing response header.
+func ServerTimingHandler(ctx context.Context) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			t := &timings{durations: make(map[string]time.Duration)}
+			b := context.WithValue(r.Context(), serverTimingKey, t)
+			next.ServeHTTP(&timingWriter{ResponseWriter: w, timings: t}, r.WithContext(b))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+
+	return m
+}
+
+// RecordTiming records the duration of the named segment, durations of the same segment are
+// added up. It's a no-op if ServerTimingHandler is not in the chain.
+func RecordTiming(r *http.Request, name string, d time.Duration) {
+	t, ok := r.Context().Value(serverTimingKey).(*timings)
+	if !ok {
+		return
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	if _, ok := t.durations[name]; !ok {
+		t.names = append(t.names, name)
+	}
+	t.durations[name] += d
+}
+
+func (t *timings) header() string {
+	t.Lock()
+	defer t.Unlock()
+	metrics := make([]string, 0, len(t.names))
+	for _, name := range t.names {
+		ms := float64(t.durations[name]) / float64(time.Millisecond)
+		metrics = append(metrics, fmt.Sprintf("%s;dur=%.1f", name, ms))
+	}
+	return strings.Join(metrics, ", ")
+}
+
+// timingWriter sets the Server-Timing header just before the headers are written
+type timingWriter struct {
+	http.ResponseWriter
+	timings     *timings
+	wroteHeader bool
+}
+
+func (tw *timingWriter) WriteHeader(status int) {
+	if !tw.wroteHeader {
+		tw.wroteHeader = true
+		if h := tw.timings.header(); h != "" {
+			tw.Header().Set("Server-Timing", h)
+		}
+	}
+	tw.ResponseWriter.WriteHeader(status)
+}
+
+func (tw *timingWriter) Write(b []byte) (int, error) {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+func (tw *timingWriter) Flush() {
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
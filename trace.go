@@ -0,0 +1,120 @@
+// Package goboot containing the trace sampling propagation. Sampling decision of the incoming
+// X-B3-Sampled or W3C traceparent header is kept and forwarded downstream, so a trace is either
+// sampled by every service or none.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// TraceSampleRate samples 1 in TraceSampleRate traces started by the service, 0 or 1 samples all
+var TraceSampleRate = 10
+
+type traceKey struct {
+	Key string
+}
+
+var traceSamplingKey = traceKey{Key: "TraceSampling"}
+
+// traceparent version-traceid-parentid-flags, see https://www.w3.org/TR/trace-context/
+var traceparentPattern = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$`)
+
+// traceSampling sampling decision of the request and the traceparent to forward
+type traceSampling struct {
+	sampled     bool
+	traceparent string
+}
+
+// TraceSamplingHandler middleware to read the sampling decision from the X-B3-Sampled, X-B3-Flags
+// or traceparent request headers, or to make one if none is set or valid.
+func TraceSamplingHandler(ctx context.Context) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ts := traceDecision(r)
+			b := context.WithValue(r.Context(), traceSamplingKey, ts)
+			next.ServeHTTP(w, r.WithContext(b))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+
+	return m
+}
+
+// TraceSampled returns whether the request trace is sampled, false if TraceSamplingHandler is
+// not in the chain
+func TraceSampled(r *http.Request) bool {
+	ts, ok := r.Context().Value(traceSamplingKey).(*traceSampling)
+	return ok && ts.sampled
+}
+
+// OutgoingHeaders returns the headers to set on the downstream requests made while handling the
+// request, with the request id and the trace sampling decision
+func OutgoingHeaders(r *http.Request) http.Header {
+	h := make(http.Header)
+	if id := RequestID(r); id != "" {
+		h.Set(RequestIDHeader, id)
+	}
+	if ts, ok := r.Context().Value(traceSamplingKey).(*traceSampling); ok {
+		if ts.sampled {
+			h.Set("X-B3-Sampled", "1")
+		} else {
+			h.Set("X-B3-Sampled", "0")
+		}
+		h.Set("traceparent", ts.traceparent)
+	}
+	return h
+}
+
+func traceDecision(r *http.Request) *traceSampling {
+	if m := traceparentPattern.FindStringSubmatch(strings.ToLower(r.Header.Get("traceparent"))); m != nil && m[1] != "ff" {
+		var flags byte
+		fmt.Sscanf(m[4], "%02x", &flags)
+		return &traceSampling{sampled: flags&0x01 == 1, traceparent: m[0]}
+	}
+
+	ts := &traceSampling{}
+	switch strings.ToLower(r.Header.Get("X-B3-Sampled")) {
+	case "1", "true", "d":
+		ts.sampled = true
+	case "0", "false":
+	default:
+		// debug flag implies sampled
+		ts.sampled = r.Header.Get("X-B3-Flags") == "1" || sampleRequest(r, TraceSampleRate)
+	}
+	ts.traceparent = newTraceparent(r, ts.sampled)
+	return ts
+}
+
+var (
+	b3TraceIDPattern = regexp.MustCompile(`^([0-9a-f]{16}){1,2}$`)
+	b3SpanIDPattern  = regexp.MustCompile(`^[0-9a-f]{16}$`)
+)
+
+// newTraceparent returns traceparent with the sampling decision, continuing the B3 trace if the
+// request has one
+func newTraceparent(r *http.Request, sampled bool) string {
+	id := make([]byte, 24)
+	rand.Read(id)
+	traceID, spanID := hex.EncodeToString(id[:16]), hex.EncodeToString(id[16:])
+	if b3 := strings.ToLower(r.Header.Get("X-B3-TraceId")); b3TraceIDPattern.MatchString(b3) {
+		// 64 bit trace ids are left padded
+		traceID = strings.Repeat("0", 32-len(b3)) + b3
+		if span := strings.ToLower(r.Header.Get("X-B3-SpanId")); b3SpanIDPattern.MatchString(span) {
+			spanID = span
+		}
+	}
+
+	flags := "00"
+	if sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", traceID, spanID, flags)
+}
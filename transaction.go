@@ -0,0 +1,113 @@
+// Package goboot containing the request scoped database transaction middleware. It implements
+// the unit-of-work pattern at the HTTP boundary, handlers get the transaction from the request
+// using Tx function and never have to commit or rollback by themselves.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+)
+
+type transaction struct {
+	Key string
+}
+
+// TxKey key for the request transaction
+var TxKey = transaction{Key: "Tx"}
+
+// TxManager begins database transactions. It's satisfied by *sql.DB so that middleware
+// is not coupled to a specific driver.
+type TxManager interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// TxHandler begins a transaction for each request and sets it into the context. Transaction
+// is committed if handler responds with 2xx status code, otherwise it's rolled back. It's also
+// rolled back on panic and the panic is passed on to the RecoverHandler.
+//
+// Whole response is buffered in memory until the commit, so the client gets the error if the
+// commit fails, and the writer doesn't implement http.Flusher. Streaming responses like the
+// WriteStream ones must be served outside of TxHandler.
+func TxHandler(ctx context.Context, tm TxManager, e ErrorHandler) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			tx, err := tm.BeginTx(r.Context(), nil)
+			if err != nil {
+				log.Printf("[ERROR] Error starting transaction: %s", err)
+				e.HandleError(r, err)
+				WriteError(w, ErrInternalServer)
+				return
+			}
+			defer func() {
+				if rr := recover(); rr != nil {
+					tx.Rollback()
+					panic(rr)
+				}
+			}()
+
+			// response is buffered so that commit failure can still be reported to the client
+			bw := &bufferedWriter{ResponseWriter: w}
+			b := context.WithValue(r.Context(), TxKey, tx)
+			next.ServeHTTP(bw, r.WithContext(b))
+
+			if bw.statusCode() >= 200 && bw.statusCode() < 300 {
+				if err := tx.Commit(); err != nil {
+					log.Printf("[ERROR] Error committing transaction: %s", err)
+					e.HandleError(r, err)
+					WriteError(w, ErrInternalServer)
+					return
+				}
+			} else {
+				tx.Rollback()
+			}
+			bw.flush()
+		}
+
+		return http.HandlerFunc(fn)
+	}
+
+	return m
+}
+
+// Tx returns the transaction of the current request, nil if TxHandler is not in the chain
+func Tx(r *http.Request) *sql.Tx {
+	tx, _ := r.Context().Value(TxKey).(*sql.Tx)
+	return tx
+}
+
+// bufferedWriter holds the response status and body until flush is called, it's deliberately not
+// a http.Flusher as nothing can be sent before the commit
+type bufferedWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (bw *bufferedWriter) WriteHeader(status int) {
+	if bw.status == 0 {
+		bw.status = status
+	}
+}
+
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	return bw.buf.Write(b)
+}
+
+func (bw *bufferedWriter) statusCode() int {
+	if bw.status == 0 {
+		return http.StatusOK
+	}
+	return bw.status
+}
+
+func (bw *bufferedWriter) flush() {
+	bw.ResponseWriter.WriteHeader(bw.statusCode())
+	bw.ResponseWriter.Write(bw.buf.Bytes())
+}
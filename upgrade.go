@@ -0,0 +1,79 @@
+// Package goboot containing the 426 Upgrade Required responses for the clients using protocols or
+// cipher suites being phased out, detected from the headers set by the load balancer.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// ErrUpgradeRequired error for the clients using outdated protocol
+var ErrUpgradeRequired = &Error{"upgrade_required", 426, "Upgrade Required", "Please upgrade to a supported protocol"}
+
+// UpgradePolicy configures the outdated protocol handling. Outdated detects the outdated clients,
+// Upgrade is the Upgrade header value like TLS/1.2 and Message is the error detail. WarnOnly adds
+// the Upgrade and Warning headers instead of rejecting, to nudge the clients before the support
+// is cut, the Warning header needs WarningHandler in the chain.
+type UpgradePolicy struct {
+	Outdated func(*http.Request) bool
+	Upgrade  string
+	Message  string
+	WarnOnly bool
+}
+
+// OutdatedHeaderValues returns Outdated detection matching the request header set by the load
+// balancer, like X-Forwarded-TLS-Version, to any of the values. Values are case insensitive.
+func OutdatedHeaderValues(header string, values ...string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		v := strings.TrimSpace(r.Header.Get(header))
+		for _, outdated := range values {
+			if v != "" && strings.EqualFold(v, outdated) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// UpgradeRequiredHandler middleware to reject, or only warn, the clients detected as outdated
+// by the policy
+func UpgradeRequiredHandler(ctx context.Context, policy UpgradePolicy) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if policy.Outdated == nil || !policy.Outdated(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !policy.WarnOnly {
+				WriteUpgradeRequired(w, policy.Upgrade, policy.Message)
+				return
+			}
+			w.Header().Set("Upgrade", policy.Upgrade)
+			message := policy.Message
+			if message == "" {
+				message = ErrUpgradeRequired.Detail
+			}
+			AddWarning(r, WarningMiscellaneousPersistent, message)
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+
+	return m
+}
+
+// WriteUpgradeRequired writes 426 Upgrade Required error with the Upgrade header, message
+// overrides the default error detail if not empty
+func WriteUpgradeRequired(w http.ResponseWriter, upgrade, message string) {
+	err := *ErrUpgradeRequired
+	if message != "" {
+		err.Detail = message
+	}
+	w.Header().Set("Upgrade", upgrade)
+	w.Header().Set("Connection", "Upgrade")
+	WriteError(w, &err)
+}
@@ -0,0 +1,64 @@
+// Package goboot containing the file upload helpers. Uploaded files are streamed part by part
+// instead of buffering the whole multipart form in memory.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUploadTooLarge error for the uploaded file larger than the limit
+var ErrUploadTooLarge = errors.New("uploaded file too large")
+
+// ExpectsContinue returns whether the client waits for the 100 Continue before sending the body.
+// Server sends the 100 Continue only when the body is first read, so the handlers and middleware
+// rejecting the request without reading the body, like the auth handlers and BodyLimitHandler,
+// reply with the final status and the client never uploads the body.
+func ExpectsContinue(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Expect"), "100-continue")
+}
+
+// StreamUpload streams the multipart file field directly to dst, without buffering it in memory,
+// and returns the bytes written and the file name. ErrUploadTooLarge is returned if the file is
+// larger than maxBytes, in that case dst has partial content and should be discarded. Reading the
+// upload sends the 100 Continue to the clients waiting for it, so any checks of the request
+// should be done before calling it.
+func StreamUpload(r *http.Request, field string, dst io.Writer, maxBytes int64) (int64, string, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return 0, "", err
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return 0, "", fmt.Errorf("file field %s not found", field)
+		}
+		if err != nil {
+			return 0, "", err
+		}
+		if part.FormName() != field || part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		defer part.Close()
+		n, err := io.Copy(dst, io.LimitReader(part, maxBytes))
+		if err != nil {
+			return n, "", err
+		}
+		if n == maxBytes {
+			// there should be nothing left to read
+			var b [1]byte
+			if _, err := io.ReadFull(part, b[:]); err == nil {
+				return n, "", ErrUploadTooLarge
+			}
+		}
+		return n, filepath.Base(part.FileName()), nil
+	}
+}
@@ -0,0 +1,93 @@
+// Package goboot containing the user agent filter to reject scrapers and known bad bots based on
+// the denylist of user agent patterns, patterns can be updated at runtime from the config.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"regexp"
+	"sync"
+)
+
+// UserAgentFilter denylist of compiled user agent patterns
+type UserAgentFilter struct {
+	sync.RWMutex
+	patterns []*regexp.Regexp
+	reject   bool
+	trusted  func(*http.Request) bool
+}
+
+// NewUserAgentFilter returns filter for the patterns. Matching requests are rejected with 403
+// only if reject is true, otherwise they are just logged.
+func NewUserAgentFilter(patterns []string, reject bool) (*UserAgentFilter, error) {
+	f := &UserAgentFilter{reject: reject}
+	if err := f.SetPatterns(patterns); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// SetPatterns compiles and replaces the patterns, so they can be reloaded from the config
+// without redeploying. Existing patterns are kept if any of the patterns is invalid.
+func (f *UserAgentFilter) SetPatterns(patterns []string) error {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return err
+		}
+		compiled = append(compiled, re)
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	f.patterns = compiled
+	return nil
+}
+
+// TrustRequests skips the filter for the requests verified by fn, like the requests of the client
+// apps carrying valid app attestation or request signature. X-App-Source header alone must not be
+// trusted, scrapers can send it as well.
+func (f *UserAgentFilter) TrustRequests(fn func(*http.Request) bool) {
+	f.Lock()
+	defer f.Unlock()
+	f.trusted = fn
+}
+
+// denied checks if request user agent matches any of the patterns
+func (f *UserAgentFilter) denied(r *http.Request) bool {
+	f.RLock()
+	defer f.RUnlock()
+	if f.trusted != nil && f.trusted(r) {
+		return false
+	}
+	ua := r.Header.Get("User-Agent")
+	for _, re := range f.patterns {
+		if re.MatchString(ua) {
+			return true
+		}
+	}
+	return false
+}
+
+// UserAgentHandler middleware to reject the requests with user agents denied by the filter
+func UserAgentHandler(ctx context.Context, f *UserAgentFilter) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if f.denied(r) {
+				log.Printf("[WARN] Denied user agent %q from %s", r.Header.Get("User-Agent"), ClientIP(r))
+				if f.reject {
+					WriteError(w, Forbidden)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+
+	return m
+}
@@ -0,0 +1,119 @@
+// Package goboot containing the validation error response. Field error messages are translated
+// to the language of the request Accept-Language header using the pluggable message catalog.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage language used when message is not translated to the request languages
+const DefaultLanguage = "en"
+
+// FieldError validation error of a single field, Rule is the failed validation rule like required
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// MessageCatalog returns the validation message of the rule in the given language. Message
+// can use {field} placeholder for the field name.
+type MessageCatalog interface {
+	Message(rule, lang string) (string, bool)
+}
+
+// Catalog simple MessageCatalog of messages by language and rule
+type Catalog map[string]map[string]string
+
+// Message MessageCatalog implementation
+func (c Catalog) Message(rule, lang string) (string, bool) {
+	msg, ok := c[lang][rule]
+	return msg, ok
+}
+
+// ValidationMessages catalog used to translate the validation messages
+var ValidationMessages MessageCatalog = Catalog{}
+
+// ValidationErrorResponse constructs error response with the field errors, messages are translated
+// to the request language falling back to English and then to the original message.
+func ValidationErrorResponse(r *http.Request, errs []FieldError) APIResponse {
+	langs := append(acceptLanguages(r), DefaultLanguage)
+	translated := make([]FieldError, len(errs))
+	for i, fe := range errs {
+		translated[i] = fe
+		for _, lang := range langs {
+			if msg, ok := ValidationMessages.Message(fe.Rule, lang); ok {
+				translated[i].Message = strings.Replace(msg, "{field}", fe.Field, -1)
+				break
+			}
+		}
+	}
+	return APIResponse{Error: "validation failed", Status: "ERROR", Data: translated}
+}
+
+// acceptLanguages returns the Accept-Language languages ordered by preference, each language
+// tag is followed by its primary language e.g. es-mx, es
+func acceptLanguages(r *http.Request) []string {
+	var langs []string
+	for _, tag := range acceptValues(r.Header.Get("Accept-Language")) {
+		if tag == "*" {
+			continue
+		}
+		langs = append(langs, tag)
+		if i := strings.Index(tag, "-"); i > 0 {
+			langs = append(langs, tag[:i])
+		}
+	}
+	return langs
+}
+
+// acceptValues returns the lower cased values of the Accept-* header ordered by their q value,
+// values with q=0 are left out
+func acceptValues(header string) []string {
+	accepted, _ := parseAccept(header)
+	return accepted
+}
+
+// parseAccept returns the lower cased values of the Accept-* header ordered by their q value and
+// the values explicitly rejected with q=0
+func parseAccept(header string) ([]string, map[string]bool) {
+	type value struct {
+		v string
+		q float64
+	}
+
+	var accepted []value
+	rejected := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		v := strings.ToLower(strings.TrimSpace(fields[0]))
+		if v == "" {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if p, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = p
+				}
+			}
+		}
+		if q > 0 {
+			accepted = append(accepted, value{v, q})
+		} else {
+			rejected[v] = true
+		}
+	}
+	sort.SliceStable(accepted, func(i, j int) bool { return accepted[i].q > accepted[j].q })
+
+	values := make([]string, 0, len(accepted))
+	for _, a := range accepted {
+		values = append(values, a.v)
+	}
+	return values, rejected
+}
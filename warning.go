@@ -0,0 +1,95 @@
+// Package goboot containing the Warning header support. Handlers and middleware add warnings like
+// deprecated field used or result truncated with AddWarning and WarningHandler writes them as
+// Warning headers (RFC 7234), visible to the proxies and clients not parsing the body.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+package goboot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Warning codes, see RFC 7234 section 5.5
+const (
+	WarningMiscellaneous           = 199
+	WarningMiscellaneousPersistent = 299
+)
+
+type warningsKey struct {
+	Key string
+}
+
+var responseWarningsKey = warningsKey{Key: "Warnings"}
+
+// quotedText escapes the warn-text quoted-string
+var quotedText = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// warnings accumulates the warnings of the request in the order they were added
+type warnings struct {
+	sync.Mutex
+	values []string
+}
+
+// WarningHandler middleware to emit the warnings added using AddWarning as Warning headers
+func WarningHandler(ctx context.Context) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			wn := &warnings{}
+			b := context.WithValue(r.Context(), responseWarningsKey, wn)
+			next.ServeHTTP(&warningWriter{ResponseWriter: w, warnings: wn}, r.WithContext(b))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+
+	return m
+}
+
+// AddWarning adds the Warning header with the code and text to the response, it's a no-op if
+// WarningHandler is not in the chain or the headers are already written.
+func AddWarning(r *http.Request, code int, text string) {
+	wn, ok := r.Context().Value(responseWarningsKey).(*warnings)
+	if !ok {
+		return
+	}
+
+	wn.Lock()
+	defer wn.Unlock()
+	// warn-agent "-" as the agent is the service itself
+	wn.values = append(wn.values, fmt.Sprintf(`%03d - "%s"`, code, quotedText.Replace(text)))
+}
+
+// warningWriter adds the Warning headers just before the headers are written
+type warningWriter struct {
+	http.ResponseWriter
+	warnings    *warnings
+	wroteHeader bool
+}
+
+func (ww *warningWriter) WriteHeader(status int) {
+	if !ww.wroteHeader {
+		ww.wroteHeader = true
+		ww.warnings.Lock()
+		for _, v := range ww.warnings.values {
+			ww.Header().Add("Warning", v)
+		}
+		ww.warnings.Unlock()
+	}
+	ww.ResponseWriter.WriteHeader(status)
+}
+
+func (ww *warningWriter) Write(b []byte) (int, error) {
+	if !ww.wroteHeader {
+		ww.WriteHeader(http.StatusOK)
+	}
+	return ww.ResponseWriter.Write(b)
+}
+
+func (ww *warningWriter) Flush() {
+	if f, ok := ww.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}